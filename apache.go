@@ -0,0 +1,141 @@
+package vfs
+
+import (
+	"html"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	apacheRowPattern  = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr\s*>`)
+	apacheCellPattern = regexp.MustCompile(`(?is)<td[^>]*>(.*?)</td\s*>`)
+	apacheAltPattern  = regexp.MustCompile(`(?is)<img\s[^>]*?alt\s*=\s*"([^"]*)"`)
+)
+
+// ApacheVFS
+// A read only VFS of a server listing directories with Apache mod_autoindex, the table layout of FancyIndexing
+// is parsed row by row, other layouts as generic HTML listings. Sizes are rounded by Apache, such as "1.2K"
+type ApacheVFS struct {
+	*HttpVFS
+}
+
+func NewApacheVFS(root string) (*ApacheVFS, error) {
+	root = strings.Trim(root, "/")
+
+	base, err := NewHttpVFS(root, "[apache]")
+	if err != nil {
+		return nil, err
+	}
+
+	apache := &ApacheVFS{
+		HttpVFS: base,
+	}
+
+	base.OpenFunc = func(name string) (fs.File, error) {
+		href, err := joinRoot(apache.Root, HierarchicalPathEncoder{}, name)
+		if err != nil {
+			return nil, err
+		}
+
+		return newListingFile(base, apache, name, *href), nil
+	}
+
+	return apache, nil
+}
+
+func (d *ApacheVFS) statURL(href *URL, name string) (*HttpFileInfo, error) {
+	return headInfo(d.HttpVFS, href, name)
+}
+
+func (d *ApacheVFS) listURL(href *URL) ([]*HttpFileInfo, error) {
+	return getHTMLListing(d.HttpVFS, href, parseApacheListing)
+}
+
+// parseApacheListing reads the icon, link, "Last modified" and "Size" cells of each row of a mod_autoindex table,
+// the parent directory row and the rows linking outside of base are skipped
+func parseApacheListing(data []byte, base *url.URL) []*HttpFileInfo {
+	dir := *base
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+		dir.RawPath = ""
+	}
+	dir.RawQuery = ""
+
+	rows := apacheRowPattern.FindAllStringSubmatch(string(data), -1)
+
+	var infos []*HttpFileInfo
+	table := false
+
+	for _, row := range rows {
+		cells := apacheCellPattern.FindAllStringSubmatch(row[1], -1)
+		if len(cells) < 4 {
+			continue
+		}
+		table = true
+
+		alt := ""
+		if match := apacheAltPattern.FindStringSubmatch(cells[0][1]); match != nil {
+			alt = strings.ToUpper(match[1])
+		}
+		if alt == "[PARENTDIR]" {
+			continue
+		}
+
+		anchor := htmlAnchorPattern.FindStringSubmatch(cells[1][1])
+		if anchor == nil {
+			continue
+		}
+		href := anchor[1]
+		if href == "" {
+			href = anchor[2]
+		}
+		ref, err := url.Parse(html.UnescapeString(href))
+		if err != nil {
+			continue
+		}
+
+		target := dir.ResolveReference(ref)
+		name := strings.TrimSuffix(target.Path, "/")
+		parent := path.Dir(name)
+		if parent != "/" {
+			parent += "/"
+		}
+		if target.Host != dir.Host || target.RawQuery != "" || name == "" || parent != dir.Path {
+			continue
+		}
+
+		isDir := strings.HasSuffix(target.Path, "/") || alt == "[DIR]"
+
+		cell := func(i int) string {
+			return strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(cells[i][1], " ")))
+		}
+
+		mtime, err := time.Parse("2006-01-02 15:04", cell(2))
+		if err != nil {
+			mtime, _ = time.Parse("2006-01-02 15:04:05", cell(2))
+		}
+
+		size := int64(0)
+		if !isDir {
+			size, _ = parseHumanSize(cell(3))
+		}
+
+		infos = append(infos, &HttpFileInfo{
+			name:  path.Base(name),
+			size:  size,
+			mode:  fs.ModePerm,
+			mtime: mtime,
+			isDir: isDir,
+		})
+	}
+
+	if !table {
+		return parseHTMLListing(data, base)
+	}
+
+	return infos
+}
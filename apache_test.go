@@ -0,0 +1,119 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apacheAutoindex is the output of Apache 2.4 mod_autoindex with FancyIndexing for /docs
+const apacheAutoindex = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html>
+ <head>
+  <title>Index of /docs</title>
+ </head>
+ <body>
+<h1>Index of /docs</h1>
+  <table>
+   <tr><th valign="top"><img src="/icons/blank.gif" alt="[ICO]"></th><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th><th><a href="?C=D;O=A">Description</a></th></tr>
+   <tr><th colspan="5"><hr></th></tr>
+<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="nested/">nested/</a></td><td align="right">2024-01-02 03:04  </td><td align="right">  - </td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/text.gif" alt="[TXT]"></td><td><a href="a.txt">a.txt</a></td><td align="right">2024-01-02 03:05  </td><td align="right"> 14 </td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/unknown.gif" alt="[   ]"></td><td><a href="big%20file.bin">big file.bin</a></td><td align="right">2023-03-15 22:10  </td><td align="right">1.2K</td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/compressed.gif" alt="[   ]"></td><td><a href="archive.tar.gz">archive.tar.gz</a></td><td align="right">2023-03-16 08:00  </td><td align="right"> 35M</td><td>&nbsp;</td></tr>
+   <tr><th colspan="5"><hr></th></tr>
+</table>
+<address>Apache/2.4.58 (Unix) Server at localhost Port 80</address>
+</body></html>
+`
+
+func TestParseApacheListing(t *testing.T) {
+	base, err := url.Parse("http://localhost/docs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listed []string
+	for _, info := range parseApacheListing([]byte(apacheAutoindex), base) {
+		listed = append(listed, fmt.Sprintf("%s %t %d %s", info.Name(), info.IsDir(), info.Size(), info.ModTime().Format(time.DateTime)))
+	}
+
+	expected := []string{
+		"nested true 0 2024-01-02 03:04:00",
+		"a.txt false 14 2024-01-02 03:05:00",
+		"big file.bin false 1228 2023-03-15 22:10:00",
+		"archive.tar.gz false 36700160 2023-03-16 08:00:00",
+	}
+	if strings.Join(listed, "\n") != strings.Join(expected, "\n") {
+		t.Fatal("unexpected entries:\n" + strings.Join(listed, "\n"))
+	}
+}
+
+func TestApacheVFS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs":
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		case "/docs/":
+			w.Header().Set("Content-Type", "text/html;charset=ISO-8859-1")
+			_, _ = io.WriteString(w, apacheAutoindex)
+		case "/docs/a.txt":
+			http.ServeContent(w, r, "a.txt", time.Time{}, strings.NewReader("first document"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	apache, err := NewApacheVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := apache.ReadDir("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[0].Name() != "a.txt" || entries[3].Name() != "nested" || !entries[3].IsDir() {
+		t.Fatal("unexpected entries:", entries)
+	}
+
+	data, err := apache.ReadFile("docs/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first document" {
+		t.Fatal("unexpected content:", string(data))
+	}
+}
+
+func TestApacheVFSRoot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html;charset=ISO-8859-1")
+		_, _ = io.WriteString(w, strings.ReplaceAll(apacheAutoindex, "/docs", "/"))
+	}))
+	defer server.Close()
+
+	apache, err := NewApacheVFS(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := apache.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[0].Name() != "a.txt" || entries[3].Name() != "nested" {
+		t.Fatal("the entries of the server root should be listed, got", entries)
+	}
+}
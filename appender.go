@@ -0,0 +1,112 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+)
+
+const DefaultAppendFlushSize = 1 << 20
+
+func (d *DufsVFS) GetAppendFlushSize() int {
+	if d.AppendFlushSize <= 0 {
+		return DefaultAppendFlushSize
+	}
+	return d.AppendFlushSize
+}
+
+// Appender
+// Buffers writes and appends them to the end of the file with a PATCH per AppendFlushSize, it is not safe for concurrent use
+type Appender struct {
+	file   *DufsFile
+	buf    []byte
+	err    error
+	closed bool
+}
+
+// Appender
+// Returns a writer appending to the file, which is created empty if missing, the remaining buffer is flushed on Close
+func (d *DufsFile) Appender() (io.WriteCloser, error) {
+	err := d.checkAccess("write", true)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, isDir, err := d.exists()
+	if err != nil {
+		return nil, err
+	}
+	if isDir {
+		return nil, &fs.PathError{Op: "append", Path: d.Name, Err: fs.ErrInvalid}
+	}
+	if !exists {
+		d.indexLocker.Lock()
+		_, err = d.upload(d.getContext(), bytes.NewReader(nil), 0)
+		d.indexLocker.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Appender{
+		file: d,
+		buf:  make([]byte, 0, d.vfs.GetAppendFlushSize()),
+	}, nil
+}
+
+func (d *Appender) Write(p []byte) (int, error) {
+	if d.closed {
+		return 0, fs.ErrClosed
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), cap(d.buf)-len(d.buf))
+		d.buf = append(d.buf, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(d.buf) == cap(d.buf) {
+			err := d.Flush()
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Flush
+// Appends the buffered bytes to the file now
+func (d *Appender) Flush() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.buf) == 0 {
+		return nil
+	}
+
+	d.file.indexLocker.Lock()
+	_, err := d.file.writeAt(d.file.getContext(), d.buf, -1)
+	d.file.indexLocker.Unlock()
+	if err != nil {
+		d.err = err
+		return err
+	}
+
+	d.buf = d.buf[:0]
+	return nil
+}
+
+func (d *Appender) Close() error {
+	if d.closed {
+		return fs.ErrClosed
+	}
+	d.closed = true
+
+	return d.Flush()
+}
@@ -0,0 +1,70 @@
+package vfs
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestDufsAppender(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.AppendFlushSize = 64 << 10
+
+	file, err := dufs.Open("log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appender, err := file.(*DufsFile).Appender()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := server.Requests.Load()
+
+	expected := bytes.NewBuffer(nil)
+	for i := 0; i < 10000; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		expected.WriteString(line)
+		_, err = appender.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = appender.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := server.get("log.txt")
+	if !bytes.Equal(data, expected.Bytes()) {
+		t.Fatal("appended content mismatch, expected", expected.Len(), "bytes, got", len(data))
+	}
+
+	flushes := int64((expected.Len() + dufs.AppendFlushSize - 1) / dufs.AppendFlushSize)
+	if requests := server.Requests.Load() - before; requests != flushes {
+		t.Fatal("10000 writes should take", flushes, "requests, got", requests)
+	}
+
+	empty, err := dufs.Open("empty.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	appender, err = empty.(*DufsFile).Appender()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = appender.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := server.get("empty.txt"); !ok || len(data) != 0 {
+		t.Fatal("closing an unused Appender should create an empty file, got", ok, len(data))
+	}
+}
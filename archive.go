@@ -0,0 +1,46 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var ErrArchiveNotAllowed = fmt.Errorf("dufs: archive not allowed: %w", ErrNotPermitted)
+
+// Archive
+// Streams dir as a zip archive made by the server, after checking the allow_archive flag of its index.
+// The caller must close the returned reader
+func (d *DufsVFS) Archive(dir string) (io.ReadCloser, error) {
+	index, err := d.capabilitiesOf(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !index.AllowArchive {
+		return nil, ErrArchiveNotAllowed
+	}
+
+	href, err := d.appendToRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	href.addQuery("zip", "")
+
+	req, err := http.NewRequest(http.MethodGet, href.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	logFields(d.GetLogger(), "Archive", "method", http.MethodGet, "url", href.String(), "status", resp.StatusCode)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		_ = resp.Body.Close()
+		return nil, statusError(resp)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,62 @@
+package vfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"testing"
+)
+
+func TestDufsArchive(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("project/main.go", []byte("package main"))
+	server.put("project/docs/README.md", []byte("# readme"))
+	server.put("elsewhere.txt", []byte("ignored"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := dufs.Archive("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(archive)
+	_ = archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, member := range reader.File {
+		names = append(names, member.Name)
+	}
+	sort.Strings(names)
+
+	if fmt.Sprint(names) != fmt.Sprint([]string{"docs/README.md", "main.go"}) {
+		t.Fatal("archive should contain docs/README.md and main.go, got", names)
+	}
+
+	server.DenyArchive = true
+
+	dufs, err = NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.Archive("project")
+	if !errors.Is(err, ErrArchiveNotAllowed) || !errors.Is(err, ErrNotPermitted) || !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("archive should fail with ErrArchiveNotAllowed when disallowed, got", err)
+	}
+}
@@ -0,0 +1,53 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"time"
+)
+
+// WriteFileAtomic
+// Uploads the content of r to a hidden sibling of name, then renames it over name with a MOVE,
+// so readers see either the previous file or the whole new one. The sibling is removed if the upload or the rename fails
+func (d *DufsVFS) WriteFileAtomic(name string, r io.Reader) error {
+	err := d.checkName("write", name)
+	if err != nil {
+		return err
+	}
+
+	normalized, err := normalizeName(name)
+	if err != nil {
+		return err
+	}
+	if normalized == "" {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	dir, base := path.Split(normalized)
+	temp := path.Join(dir, "."+base+"."+strconv.FormatInt(time.Now().UnixNano(), 36)+".tmp")
+
+	href, err := d.appendToRoot(temp)
+	if err != nil {
+		return err
+	}
+
+	file := NewDufsFile(d, temp, *href)
+	file.ContentType = contentTypeOf("", normalized, nil)
+
+	_, err = file.ReadFrom(r)
+	if err == nil {
+		err = d.RenameWithOpts(temp, normalized, RenameOpts{Overwrite: OverwriteReplace})
+	}
+	if err != nil {
+		removeErr := d.Remove(temp)
+		if removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			logFields(d.GetLogger(), "Remove temporary file", "name", temp, "error", removeErr)
+		}
+		return err
+	}
+
+	return nil
+}
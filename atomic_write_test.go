@@ -0,0 +1,96 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// checkedReader calls check before each read of Reader
+type checkedReader struct {
+	io.Reader
+	check func()
+}
+
+func (d *checkedReader) Read(p []byte) (int, error) {
+	d.check()
+	return d.Reader.Read(p)
+}
+
+func TestDufsWriteFileAtomic(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("reports/report.csv", []byte("previous,report"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SmallFileThreshold = 10
+	dufs.PartSize = 10
+
+	files := func() []string {
+		entries, err := dufs.ReadDir("reports")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	checks := 0
+	changed := ""
+	err = dufs.WriteFileAtomic("reports/report.csv", &failingReader{
+		Reader: &checkedReader{
+			Reader: strings.NewReader(strings.Repeat("partial,", 10)),
+			check: func() {
+				checks++
+				if data, _ := server.get("reports/report.csv"); string(data) != "previous,report" && changed == "" {
+					changed = string(data)
+				}
+			},
+		},
+		Limit: 40,
+	})
+	if !errors.Is(err, errInjectedFault) {
+		t.Fatal("the upload should fail with the error of the reader, got", err)
+	}
+	if changed != "" {
+		t.Fatal("the destination should not change during the upload, got", changed)
+	}
+	if checks < 4 {
+		t.Fatal("the content should be uploaded in parts, got", checks, "reads")
+	}
+	if names := files(); strings.Join(names, ",") != "report.csv" {
+		t.Fatal("the temporary file should be removed, got", names)
+	}
+
+	err = dufs.WriteFileAtomic("reports/report.csv", strings.NewReader("new,report,with,more,columns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := server.get("reports/report.csv"); string(data) != "new,report,with,more,columns" {
+		t.Fatal("the destination should be replaced, got", string(data))
+	}
+	if names := files(); strings.Join(names, ",") != "report.csv" {
+		t.Fatal("only the destination should remain, got", names)
+	}
+
+	err = dufs.WriteFileAtomic("", strings.NewReader("new"))
+	if err == nil {
+		t.Fatal("the root can not be written")
+	}
+
+	dufs.StrictFS = true
+	err = dufs.WriteFileAtomic("reports//report.csv", strings.NewReader("strict"))
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("StrictFS should reject an invalid name, got", err)
+	}
+	if data, _ := server.get("reports/report.csv"); string(data) != "new,report,with,more,columns" {
+		t.Fatal("an invalid name should not be written, got", string(data))
+	}
+}
@@ -0,0 +1,333 @@
+package vfs
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheMaxSize    = 32 << 20
+	DefaultCacheMaxEntries = 16 << 10
+)
+
+type CachingOpts struct {
+	// MaxSize is the total size of the contents kept, the least recently used are evicted beyond it, DefaultCacheMaxSize if 0
+	MaxSize int64
+	// MaxEntries is the number of names kept, with a content or only a Stat, the least recently used are evicted beyond it,
+	// DefaultCacheMaxEntries if 0
+	MaxEntries int
+	// TTL is how long a content or Stat is served from the cache, forever if 0
+	TTL time.Duration
+}
+
+func (d CachingOpts) GetMaxSize() int64 {
+	if d.MaxSize <= 0 {
+		return DefaultCacheMaxSize
+	}
+	return d.MaxSize
+}
+
+func (d CachingOpts) GetMaxEntries() int {
+	if d.MaxEntries <= 0 {
+		return DefaultCacheMaxEntries
+	}
+	return d.MaxEntries
+}
+
+// CachingVFS
+// A VFS serving ReadFile and Stat from memory once they succeeded. The entries of a file, its subtree and its parents
+// are dropped when it is changed through this VFS: by Mkdir, Remove, Rename, Copy or a write to one of its files.
+// Changes made by other clients are seen once the TTL is over
+type CachingVFS struct {
+	VFS
+
+	Opts CachingOpts
+
+	locker  sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List
+	size    int64
+	// generation is incremented by every Invalidate, so a fill started before one is not stored
+	generation uint64
+}
+
+type cacheEntry struct {
+	name     string
+	data     []byte
+	hasData  bool
+	stat     fs.FileInfo
+	cachedAt time.Time
+}
+
+func Caching(vfs VFS, opts CachingOpts) VFS {
+	return &CachingVFS{
+		VFS:     vfs,
+		Opts:    opts,
+		entries: map[string]*list.Element{},
+	}
+}
+
+// cacheKey is the normalized name, so "a//b" and "a/b" share an entry
+func cacheKey(name string) (string, bool) {
+	key, err := normalizeName(name)
+	return key, err == nil
+}
+
+// strictFS is a VFS with the StrictFS setting, such as a DufsVFS
+type strictFS interface {
+	isStrictFS() bool
+}
+
+// lookupKey is the cacheKey of name, but a name not valid for fs.ValidPath is never served from the cache
+// if the wrapped VFS is strict, so it fails there with fs.ErrInvalid instead of hitting the entry of its normalized name
+func (d *CachingVFS) lookupKey(name string) (string, bool) {
+	if strict, ok := d.VFS.(strictFS); ok && strict.isStrictFS() && !fs.ValidPath(name) {
+		return "", false
+	}
+	return cacheKey(name)
+}
+
+// get returns the live entry of key, moved to the front of the LRU
+func (d *CachingVFS) get(key string) *cacheEntry {
+	element, ok := d.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if d.Opts.TTL > 0 && time.Since(entry.cachedAt) >= d.Opts.TTL {
+		d.drop(element)
+		return nil
+	}
+
+	d.lru.MoveToFront(element)
+	return entry
+}
+
+// put returns the entry of key, created if missing
+func (d *CachingVFS) put(key string) *cacheEntry {
+	if entry := d.get(key); entry != nil {
+		return entry
+	}
+
+	entry := &cacheEntry{name: key, cachedAt: time.Now()}
+	d.entries[key] = d.lru.PushFront(entry)
+	return entry
+}
+
+func (d *CachingVFS) drop(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	d.size -= int64(len(entry.data))
+	d.lru.Remove(element)
+	delete(d.entries, entry.name)
+}
+
+// evict drops the least recently used entries until there are at most MaxEntries,
+// then the least recently used contents until they fit in MaxSize
+func (d *CachingVFS) evict() {
+	for element := d.lru.Back(); element != nil && d.lru.Len() > d.Opts.GetMaxEntries(); element = d.lru.Back() {
+		d.drop(element)
+	}
+	for element := d.lru.Back(); element != nil && d.size > d.Opts.GetMaxSize(); {
+		previous := element.Prev()
+		if element.Value.(*cacheEntry).hasData {
+			d.drop(element)
+		}
+		element = previous
+	}
+}
+
+// Invalidate
+// Drops the entries of name, of everything under it, and of its parents, whose listings and mtimes may have changed
+func (d *CachingVFS) Invalidate(name string) {
+	key, ok := cacheKey(name)
+	if !ok {
+		return
+	}
+
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	d.generation++
+	for cached, element := range d.entries {
+		if key == "" || cached == key || strings.HasPrefix(cached, key+"/") || cached == "" || strings.HasPrefix(key, cached+"/") {
+			d.drop(element)
+		}
+	}
+}
+
+func (d *CachingVFS) ReadFile(name string) ([]byte, error) {
+	key, ok := d.lookupKey(name)
+	if !ok {
+		return d.VFS.ReadFile(name)
+	}
+
+	d.locker.Lock()
+	if entry := d.get(key); entry != nil && entry.hasData {
+		data := append([]byte(nil), entry.data...)
+		d.locker.Unlock()
+		return data, nil
+	}
+	generation := d.generation
+	d.locker.Unlock()
+
+	data, err := d.VFS.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// a write invalidating name while it was read may have changed it after it was read
+	if int64(len(data)) <= d.Opts.GetMaxSize() {
+		d.locker.Lock()
+		if d.generation != generation {
+			d.locker.Unlock()
+			return data, nil
+		}
+		entry := d.put(key)
+		if !entry.hasData {
+			entry.data = append([]byte(nil), data...)
+			entry.hasData = true
+			d.size += int64(len(entry.data))
+			d.evict()
+		}
+		d.locker.Unlock()
+	}
+
+	return data, nil
+}
+
+func (d *CachingVFS) Stat(name string) (fs.FileInfo, error) {
+	key, ok := d.lookupKey(name)
+	if !ok {
+		return d.VFS.Stat(name)
+	}
+
+	d.locker.Lock()
+	if entry := d.get(key); entry != nil && entry.stat != nil {
+		d.locker.Unlock()
+		return entry.stat, nil
+	}
+	generation := d.generation
+	d.locker.Unlock()
+
+	stat, err := d.VFS.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	d.locker.Lock()
+	if d.generation == generation {
+		d.put(key).stat = stat
+		d.evict()
+	}
+	d.locker.Unlock()
+
+	return stat, nil
+}
+
+// Open
+// The reads of the file are not cached, a write through it invalidates name
+func (d *CachingVFS) Open(name string) (fs.File, error) {
+	file, err := d.VFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &cachingFile{
+		passthroughFile: passthroughFile{File: file, name: name},
+		vfs:             d,
+	}, nil
+}
+
+// mutator is the part of a VFS such as DufsVFS changing the tree
+type mutator interface {
+	Mkdir(name string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldname, newname string) error
+	Copy(dst, src string) error
+}
+
+// mutate runs fn with the wrapped VFS if it is a mutator, then invalidates names
+func (d *CachingVFS) mutate(op, name string, fn func(vfs mutator) error, names ...string) error {
+	vfs, ok := d.VFS.(mutator)
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: errors.ErrUnsupported}
+	}
+	defer func() {
+		for _, name := range names {
+			d.Invalidate(name)
+		}
+	}()
+	return fn(vfs)
+}
+
+func (d *CachingVFS) Mkdir(name string, perm fs.FileMode) error {
+	return d.mutate("mkdir", name, func(vfs mutator) error {
+		return vfs.Mkdir(name, perm)
+	}, name)
+}
+
+func (d *CachingVFS) Remove(name string) error {
+	return d.mutate("remove", name, func(vfs mutator) error {
+		return vfs.Remove(name)
+	}, name)
+}
+
+func (d *CachingVFS) Rename(oldname, newname string) error {
+	return d.mutate("rename", oldname, func(vfs mutator) error {
+		return vfs.Rename(oldname, newname)
+	}, oldname, newname)
+}
+
+func (d *CachingVFS) Copy(dst, src string) error {
+	return d.mutate("copy", dst, func(vfs mutator) error {
+		return vfs.Copy(dst, src)
+	}, dst)
+}
+
+// cachingFile passes everything through to File, invalidating its name after a write
+type cachingFile struct {
+	passthroughFile
+
+	vfs *CachingVFS
+}
+
+func (d *cachingFile) ReadFrom(reader io.Reader) (int64, error) {
+	defer d.vfs.Invalidate(d.name)
+	readerFrom, ok := d.File.(io.ReaderFrom)
+	if !ok {
+		return 0, &fs.PathError{Op: "write", Path: d.name, Err: errors.ErrUnsupported}
+	}
+	return readerFrom.ReadFrom(reader)
+}
+
+func (d *cachingFile) Write(p []byte) (int, error) {
+	defer d.vfs.Invalidate(d.name)
+	writer, ok := d.File.(io.Writer)
+	if !ok {
+		return 0, &fs.PathError{Op: "write", Path: d.name, Err: errors.ErrUnsupported}
+	}
+	return writer.Write(p)
+}
+
+func (d *cachingFile) WriteAt(p []byte, off int64) (int, error) {
+	defer d.vfs.Invalidate(d.name)
+	writerAt, ok := d.File.(io.WriterAt)
+	if !ok {
+		return 0, &fs.PathError{Op: "write", Path: d.name, Err: errors.ErrUnsupported}
+	}
+	return writerAt.WriteAt(p, off)
+}
+
+func (d *cachingFile) Truncate(size int64) error {
+	defer d.vfs.Invalidate(d.name)
+	truncater, ok := d.File.(interface{ Truncate(size int64) error })
+	if !ok {
+		return &fs.PathError{Op: "truncate", Path: d.name, Err: errors.ErrUnsupported}
+	}
+	return truncater.Truncate(size)
+}
@@ -0,0 +1,225 @@
+package vfs
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCaching(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a.txt", []byte("aaaa"))
+	server.put("b.txt", []byte("bbbb"))
+	server.put("dir/c.txt", []byte("cccc"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := Caching(dufs, CachingOpts{MaxSize: 8}).(*CachingVFS)
+
+	read := func(name, expected string) {
+		t.Helper()
+		data, err := cache.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Fatalf("%s should read %q, got %q", name, expected, data)
+		}
+	}
+	requests := func() int64 {
+		return server.Requests.Load()
+	}
+
+	read("a.txt", "aaaa")
+	sent := requests()
+	read("a.txt", "aaaa")
+	read("/a.txt", "aaaa")
+	if requests() != sent {
+		t.Fatal("a hit should not send a request")
+	}
+
+	_, err = cache.Stat("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent = requests()
+	_, err = cache.Stat("a.txt")
+	if err != nil || requests() != sent {
+		t.Fatal("a cached Stat should not send a request", err)
+	}
+
+	// 4 + 4 bytes fit, the third file evicts the least recently used, a.txt is used last
+	read("b.txt", "bbbb")
+	read("a.txt", "aaaa")
+	read("dir/c.txt", "cccc")
+	if cache.size > 8 {
+		t.Fatal("the cache should stay under MaxSize, got", cache.size)
+	}
+	sent = requests()
+	read("a.txt", "aaaa")
+	if requests() != sent {
+		t.Fatal("a.txt should still be cached")
+	}
+	read("b.txt", "bbbb")
+	if requests() == sent {
+		t.Fatal("b.txt should have been evicted")
+	}
+
+	file, err := cache.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(File).ReadFrom(strings.NewReader("written"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read("a.txt", "written")
+
+	stat, err := cache.Stat("a.txt")
+	if err != nil || stat.Size() != 7 {
+		t.Fatal("Stat should be invalidated by a write, got", stat, err)
+	}
+
+	read("dir/c.txt", "cccc")
+	err = cache.Rename("dir", "moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.ReadFile("dir/c.txt")
+	if err == nil {
+		t.Fatal("a renamed directory should invalidate its files")
+	}
+	read("moved/c.txt", "cccc")
+
+	err = cache.Remove("moved/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.Stat("moved/c.txt")
+	if err == nil {
+		t.Fatal("a removed file should be invalidated")
+	}
+}
+
+func TestCachingTTL(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("ttl.txt", []byte("old"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := Caching(dufs, CachingOpts{TTL: 50 * time.Millisecond})
+
+	data, err := cache.ReadFile("ttl.txt")
+	if err != nil || !bytes.Equal(data, []byte("old")) {
+		t.Fatal(string(data), err)
+	}
+
+	server.put("ttl.txt", []byte("new"))
+
+	data, _ = cache.ReadFile("ttl.txt")
+	if string(data) != "old" {
+		t.Fatal("a change by another client should be hidden until the TTL is over, got", string(data))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	data, _ = cache.ReadFile("ttl.txt")
+	if string(data) != "new" {
+		t.Fatal("an expired entry should be read again, got", string(data))
+	}
+}
+
+// slowReadVFS reads the file and closes got the first time, then waits for release to be closed before returning it
+type slowReadVFS struct {
+	VFS
+
+	once    sync.Once
+	got     chan struct{}
+	release chan struct{}
+}
+
+func (d *slowReadVFS) ReadFile(name string) ([]byte, error) {
+	data, err := d.VFS.ReadFile(name)
+	d.once.Do(func() {
+		close(d.got)
+	})
+	<-d.release
+	return data, err
+}
+
+func TestCachingInvalidateDuringRead(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("racy.txt", []byte("before"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slow := &slowReadVFS{VFS: dufs, got: make(chan struct{}), release: make(chan struct{})}
+	cache := Caching(slow, CachingOpts{}).(*CachingVFS)
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := cache.ReadFile("racy.txt")
+		done <- data
+	}()
+
+	// the read got the old content, then the file is written and invalidated before the read stores it
+	<-slow.got
+	server.put("racy.txt", []byte("after"))
+	cache.Invalidate("racy.txt")
+	close(slow.release)
+
+	if data := <-done; string(data) != "before" {
+		t.Fatal("the read should return what it read, got", string(data))
+	}
+
+	data, err := cache.ReadFile("racy.txt")
+	if err != nil || string(data) != "after" {
+		t.Fatal("a read overlapping an Invalidate should not be cached, got", string(data), err)
+	}
+}
+
+func TestCachingMaxEntries(t *testing.T) {
+	server := newFakeDufs(t)
+	for i := 0; i < 10; i++ {
+		server.put(strconv.Itoa(i)+".txt", []byte("stat"))
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := Caching(dufs, CachingOpts{MaxEntries: 4}).(*CachingVFS)
+	for i := 0; i < 10; i++ {
+		_, err = cache.Stat(strconv.Itoa(i) + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(cache.entries) != 4 || cache.lru.Len() != 4 {
+		t.Fatal("Stat entries should be evicted beyond MaxEntries, got", len(cache.entries))
+	}
+
+	sent := server.Requests.Load()
+	_, err = cache.Stat("9.txt")
+	if err != nil || server.Requests.Load() != sent {
+		t.Fatal("the most recent Stat should be kept, got", err)
+	}
+	_, err = cache.Stat("0.txt")
+	if err != nil || server.Requests.Load() == sent {
+		t.Fatal("the least recent Stat should be evicted, got", err)
+	}
+}
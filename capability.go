@@ -0,0 +1,167 @@
+package vfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultCapabilityTTL = time.Minute
+
+var ErrNotPermitted = fmt.Errorf("dufs: operation not permitted: %w", fs.ErrPermission)
+
+var ErrUploadNotAllowed = fmt.Errorf("dufs: upload not allowed: %w", ErrNotPermitted)
+
+// Capabilities
+// What the server allows in a directory, and who it is talking to
+type Capabilities struct {
+	AllowUpload  bool
+	AllowDelete  bool
+	AllowSearch  bool
+	AllowArchive bool
+	// Auth is whether the server requires authentication
+	Auth bool
+	// User is the authenticated user, empty if anonymous
+	User      string
+	UriPrefix string
+}
+
+type capabilityEntry struct {
+	index   DufsJSONIndex
+	expires time.Time
+}
+
+type capabilityCache struct {
+	locker  sync.Mutex
+	entries map[string]*capabilityEntry
+}
+
+func (d *DufsVFS) GetCapabilityTTL() time.Duration {
+	if d.CapabilityTTL <= 0 {
+		return DefaultCapabilityTTL
+	}
+	return d.CapabilityTTL
+}
+
+// capabilitiesOf returns the index of dir without its paths, cached for CapabilityTTL
+func (d *DufsVFS) capabilitiesOf(dir string) (DufsJSONIndex, error) {
+	d.capabilities.locker.Lock()
+	entry, ok := d.capabilities.entries[dir]
+	d.capabilities.locker.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.index, nil
+	}
+
+	href, err := d.appendToRoot(dir)
+	if err != nil {
+		return DufsJSONIndex{}, err
+	}
+
+	file := NewDufsFile(d, dir, *href)
+	resp, err := file.get(file.getContext(), nil)
+	if err != nil {
+		return DufsJSONIndex{}, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return DufsJSONIndex{}, ErrNoJSONListing
+	}
+
+	var index DufsJSONIndex
+	err = json.NewDecoder(resp.Body).Decode(&index)
+	if err != nil {
+		return DufsJSONIndex{}, err
+	}
+	index.Paths = nil
+
+	d.capabilities.locker.Lock()
+	if d.capabilities.entries == nil {
+		d.capabilities.entries = map[string]*capabilityEntry{}
+	}
+	d.capabilities.entries[dir] = &capabilityEntry{
+		index:   index,
+		expires: time.Now().Add(d.GetCapabilityTTL()),
+	}
+	d.capabilities.locker.Unlock()
+
+	return index, nil
+}
+
+// Capabilities
+// Returns the flags of the json index of dir, cached for CapabilityTTL like the ones used by EnforceCapabilities
+func (d *DufsVFS) Capabilities(dir string) (Capabilities, error) {
+	index, err := d.capabilitiesOf(strings.Trim(dir, "/"))
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	return Capabilities{
+		AllowUpload:  index.AllowUpload,
+		AllowDelete:  index.AllowDelete,
+		AllowSearch:  index.AllowSearch,
+		AllowArchive: index.AllowArchive,
+		Auth:         index.Auth,
+		User:         index.User,
+		UriPrefix:    index.UriPrefix,
+	}, nil
+}
+
+// checkCapability fails with the error of check, such as ErrUploadNotAllowed, if EnforceCapabilities is on
+// and the parent directory of name disallows op.
+// The check is skipped when the capabilities can not be read, the server will decide then
+func (d *DufsVFS) checkCapability(op, name string, check func(index DufsJSONIndex) error) error {
+	dir := path.Dir(strings.Trim(name, "/"))
+	if dir == "." {
+		dir = ""
+	}
+	return d.checkDirCapability(op, name, dir, check)
+}
+
+// checkDirCapability is checkCapability of the directory dir itself
+func (d *DufsVFS) checkDirCapability(op, name, dir string, check func(index DufsJSONIndex) error) error {
+	if !d.EnforceCapabilities {
+		return nil
+	}
+
+	index, err := d.capabilitiesOf(dir)
+	if err != nil {
+		logFields(d.GetLogger(), "Skip capability check", "op", op, "name", name, "error", err)
+		return nil
+	}
+
+	err = check(index)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return nil
+}
+
+func canUpload(index DufsJSONIndex) error {
+	if !index.AllowUpload {
+		return ErrUploadNotAllowed
+	}
+	return nil
+}
+
+func canDelete(index DufsJSONIndex) error {
+	if !index.AllowDelete {
+		return ErrNotPermitted
+	}
+	return nil
+}
+
+func canSearch(index DufsJSONIndex) error {
+	if !index.AllowSearch {
+		return ErrNotPermitted
+	}
+	return nil
+}
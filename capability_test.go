@@ -0,0 +1,149 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestDufsEnforceCapabilities(t *testing.T) {
+	server := newFakeDufs(t)
+	server.DenyDelete = true
+	server.put("keep/file.txt", []byte("keep"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.EnforceCapabilities = true
+
+	err = dufs.Remove("keep/file.txt")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("remove should fail with fs.ErrPermission, got", err)
+	}
+
+	before := server.Requests.Load()
+
+	err = dufs.Remove("keep/file.txt")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("remove should fail with fs.ErrPermission, got", err)
+	}
+	err = dufs.Rename("keep/file.txt", "keep/moved.txt")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("rename should fail with fs.ErrPermission, got", err)
+	}
+
+	if requests := server.Requests.Load() - before; requests != 0 {
+		t.Fatal("capabilities should be cached, got", requests, "requests")
+	}
+
+	file, err := dufs.Open("keep/new.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).ReadFrom(strings.NewReader("uploads are allowed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := server.get("keep/new.txt"); !ok {
+		t.Fatal("keep/new.txt should be uploaded")
+	}
+}
+
+func TestDufsCapabilities(t *testing.T) {
+	server := newFakeDufs(t)
+	server.DenyDelete = true
+	server.DenySearch = true
+	server.User = "admin"
+	server.put("docs/readme.txt", []byte("read me"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capabilities, err := dufs.Capabilities("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Capabilities{
+		AllowUpload:  true,
+		AllowArchive: true,
+		Auth:         true,
+		User:         "admin",
+		UriPrefix:    "/",
+	}
+	if capabilities != expected {
+		t.Fatalf("expected %+v, got %+v", expected, capabilities)
+	}
+
+	_, err = dufs.Capabilities("missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("the capabilities of a missing directory should fail with fs.ErrNotExist, got", err)
+	}
+
+	dufs.EnforceCapabilities = true
+	before := server.Requests.Load()
+
+	_, err = dufs.Search("docs", "readme")
+	if !errors.Is(err, ErrNotPermitted) || !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("search should fail with ErrNotPermitted, got", err)
+	}
+	err = dufs.Remove("docs/readme.txt")
+	if !errors.Is(err, ErrNotPermitted) {
+		t.Fatal("remove should fail with ErrNotPermitted, got", err)
+	}
+
+	if requests := server.Requests.Load() - before; requests != 0 {
+		t.Fatal("the cached capabilities should be checked before sending anything, got", requests, "requests")
+	}
+}
+
+// unreadable reports the test an error if it is read, and records it in read
+type unreadable struct {
+	t    *testing.T
+	read bool
+}
+
+func (d *unreadable) Read([]byte) (int, error) {
+	d.t.Error("the body of a rejected upload should not be read")
+	d.read = true
+	return 0, io.EOF
+}
+
+func TestDufsUploadNotAllowed(t *testing.T) {
+	server := newFakeDufs(t)
+	server.DenyUpload = true
+	server.put("read-only/file.txt", []byte("original"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.EnforceCapabilities = true
+
+	file, err := dufs.Open("read-only/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &unreadable{t: t}
+	_, err = file.(*DufsFile).ReadFrom(body)
+	if !errors.Is(err, ErrUploadNotAllowed) || !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("ReadFrom should fail with ErrUploadNotAllowed, got", err)
+	}
+	if body.read {
+		t.FailNow()
+	}
+	_, err = file.(*DufsFile).WriteAt([]byte("patched"), 0)
+	if !errors.Is(err, ErrUploadNotAllowed) {
+		t.Fatal("WriteAt should fail with ErrUploadNotAllowed, got", err)
+	}
+
+	if data, _ := server.get("read-only/file.txt"); string(data) != "original" {
+		t.Fatal("the file should be untouched, got", string(data))
+	}
+}
@@ -0,0 +1,84 @@
+package vfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// EqualsLocal
+// Reports whether the remote file name has the same content as the local file at localPath,
+// the remote file is only downloaded if the sizes match but the mtimes do not
+func (d *DufsVFS) EqualsLocal(name, localPath string) (bool, error) {
+	local, err := os.Open(localPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = local.Close()
+	}()
+
+	localStat, err := local.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	remote, err := d.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = remote.Close()
+	}()
+
+	remoteStat, err := remote.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if localStat.IsDir() || remoteStat.IsDir() {
+		return false, fs.ErrInvalid
+	}
+
+	return sameFile(localStat, remoteStat, func() ([]byte, error) {
+		return sha256Sum(local)
+	}, func() ([]byte, error) {
+		return sha256Sum(remote)
+	})
+}
+
+// sameFile is the comparison of EqualsLocal: files of different sizes differ,
+// files of the same size and mtime, to the second, are the same, only the others are hashed
+func sameFile(a, b fs.FileInfo, hashA, hashB func() ([]byte, error)) (bool, error) {
+	if a.Size() != b.Size() {
+		return false, nil
+	}
+
+	if a.ModTime().Truncate(time.Second).Equal(b.ModTime().Truncate(time.Second)) {
+		return true, nil
+	}
+
+	ha, err := hashA()
+	if err != nil {
+		return false, err
+	}
+
+	hb, err := hashB()
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(ha, hb), nil
+}
+
+func sha256Sum(reader io.Reader) ([]byte, error) {
+	hasher := sha256.New()
+	_, err := io.Copy(hasher, reader)
+	if err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
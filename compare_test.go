@@ -0,0 +1,62 @@
+package vfs
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestDufsEqualsLocal(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("remote.txt", []byte("same content"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+
+	dir := t.TempDir()
+	cases := map[string]struct {
+		content  string
+		mtime    time.Time
+		equal    bool
+		requests int64
+	}{
+		"equal.txt":     {"same content", old, true, 2},
+		"different.txt": {"same_content", old, false, 2},
+		// no download: sizes differ
+		"shorter.txt": {"same", old, false, 1},
+		// no download: same size and mtime
+		"untouched.txt": {"same content", server.mtimes["remote.txt"], true, 1},
+	}
+
+	for name, c := range cases {
+		localPath := path.Join(dir, name)
+		err = os.WriteFile(localPath, []byte(c.content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(localPath, c.mtime, c.mtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before := server.Requests.Load()
+
+		equal, err := dufs.EqualsLocal("remote.txt", localPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if equal != c.equal {
+			t.Fatal(name, "should be equal:", c.equal, ", got", equal)
+		}
+
+		if requests := server.Requests.Load() - before; requests != c.requests {
+			t.Fatal(name, "should take", c.requests, "requests, got", requests)
+		}
+	}
+}
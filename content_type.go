@@ -0,0 +1,61 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// sniffLen is how many bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// contentTypeOf is the Content-Type to upload name with: override if set, the type of its extension if known,
+// or else the type sniffed from head, "" if head is empty
+func contentTypeOf(override, name string, head []byte) string {
+	if override != "" {
+		return override
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	if len(head) == 0 {
+		return ""
+	}
+	return http.DetectContentType(head)
+}
+
+// sniffContentType is contentTypeOf the first bytes of reader, returned with a reader still yielding all of its content.
+// A seekable reader is rewound, so it can still be rewound on retry
+func sniffContentType(override, name string, reader io.Reader) (string, io.Reader, error) {
+	if contentType := contentTypeOf(override, name, nil); contentType != "" {
+		return contentType, reader, nil
+	}
+
+	seeker, seekable := reader.(io.Seeker)
+	start := int64(0)
+	if seekable {
+		var err error
+		start, err = seeker.Seek(0, io.SeekCurrent)
+		seekable = err == nil
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	if seekable {
+		_, err = seeker.Seek(start, io.SeekStart)
+		if err != nil {
+			return "", nil, err
+		}
+	} else {
+		reader = io.MultiReader(bytes.NewReader(head), reader)
+	}
+
+	return contentTypeOf(override, name, head), reader, nil
+}
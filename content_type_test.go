@@ -0,0 +1,106 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pngHeader is enough of a PNG for http.DetectContentType
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+
+func TestDufsUploadContentType(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		override string
+		reader   io.Reader
+		expected string
+	}{
+		{"image.png", "", bytes.NewReader([]byte("not really a png")), "image/png"},
+		{"image", "", io.MultiReader(bytes.NewReader(pngHeader)), "image/png"},
+		{"seekable", "", bytes.NewReader(pngHeader), "image/png"},
+		{"notes", "", strings.NewReader("plain notes"), "text/plain; charset=utf-8"},
+		{"forced.png", "application/x-custom", bytes.NewReader(pngHeader), "application/x-custom"},
+	} {
+		file, err := dufs.Open(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.(*DufsFile).ContentType = c.override
+
+		_, err = file.(io.ReaderFrom).ReadFrom(c.reader)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+
+		contentType, err := dufs.ContentType(c.name)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if contentType != c.expected {
+			t.Fatal(c.name, "should be uploaded as", c.expected, "got", contentType)
+		}
+	}
+
+	data, _ := server.get("image")
+	if !bytes.Equal(data, pngHeader) {
+		t.Fatal("the sniffed bytes should still be uploaded, got", data)
+	}
+	data, _ = server.get("seekable")
+	if !bytes.Equal(data, pngHeader) {
+		t.Fatal("the sniffed bytes should still be uploaded, got", data)
+	}
+}
+
+func TestDufsWriteAtContentType(t *testing.T) {
+	var locker sync.Mutex
+	var contentTypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locker.Lock()
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		locker.Unlock()
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).WriteAt([]byte("patched"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = dufs.Open("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).WriteAt(pngHeader, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).WriteAt([]byte("patched"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(contentTypes, ",") != "image/png,image/png," {
+		t.Fatal("a PATCH should be sent with the type of the extension, or sniffed at offset 0, got", contentTypes)
+	}
+}
@@ -0,0 +1,81 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+var ErrCopyConflict = fmt.Errorf("dufs: copy destination exists: %w", fs.ErrExist)
+
+// CopyDir
+// Copies the tree under src to dst with a Mkdir per directory and a server side Copy per file,
+// dst and its existing subdirectories are merged into, but an existing file fails with ErrCopyConflict,
+// as does a dst inside src, whose copies would be copied again endlessly
+func (d *DufsVFS) CopyDir(dst, src string) error {
+	normalizedDst, err := normalizeName(dst)
+	if err != nil {
+		return err
+	}
+	normalizedSrc, err := normalizeName(src)
+	if err != nil {
+		return err
+	}
+	if normalizedDst == normalizedSrc || normalizedSrc == "" || strings.HasPrefix(normalizedDst, normalizedSrc+"/") {
+		return &fs.PathError{Op: "copy", Path: dst, Err: ErrCopyConflict}
+	}
+
+	err = d.MkdirAll(path.Dir(path.Clean("/"+dst)), fs.ModePerm)
+	if err != nil {
+		return err
+	}
+	return d.copyDir(dst, src)
+}
+
+func (d *DufsVFS) copyDir(dst, src string) error {
+	err := d.Mkdir(dst, fs.ModePerm)
+	if errors.Is(err, fs.ErrExist) {
+		stat, err := d.Stat(dst)
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			return &fs.PathError{Op: "copy", Path: dst, Err: ErrCopyConflict}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	entries, err := d.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		from, to := path.Join(src, entry.Name()), path.Join(dst, entry.Name())
+
+		if entry.IsDir() {
+			err = d.copyDir(to, from)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err = d.Stat(to)
+		if err == nil {
+			return &fs.PathError{Op: "copy", Path: to, Err: ErrCopyConflict}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		err = d.Copy(to, from)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestDufsCopyDir(t *testing.T) {
+	server := newFakeDufs(t)
+
+	tree := map[string]string{
+		"src/top.txt":           "top",
+		"src/level1/a.txt":      "a",
+		"src/level1/level2/b":   "b",
+		"src/level1/level2/c.c": "c",
+	}
+	for name, content := range tree {
+		server.put(name, []byte(content))
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dufs.CopyDir("backup/dst", "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range tree {
+		expected, err := Sha256([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, ok := server.get("backup/dst" + name[len("src"):])
+		if !ok {
+			t.Fatal(name, "should be copied")
+		}
+		hash, err := Sha256(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != expected {
+			t.Fatal("hash mismatch for", name)
+		}
+	}
+
+	err = dufs.CopyDir("backup/dst", "src")
+	if !errors.Is(err, ErrCopyConflict) || !errors.Is(err, fs.ErrExist) {
+		t.Fatal("copying over existing files should fail with ErrCopyConflict, got", err)
+	}
+}
+
+func TestDufsCopyDirIntoItself(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a/b/file.txt", []byte("file"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{"a/b", "/a//b/c", "a", "a/"} {
+		err = dufs.CopyDir(dst, "a")
+		if !errors.Is(err, ErrCopyConflict) {
+			t.Fatal("copying a into", dst, "should fail with ErrCopyConflict, got", err)
+		}
+	}
+
+	before := len(server.files)
+	err = dufs.CopyDir("ab", "a")
+	if err != nil || len(server.files) != before+1 {
+		t.Fatal("a sibling sharing the prefix of src should be copied into, got", err)
+	}
+}
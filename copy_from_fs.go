@@ -0,0 +1,120 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+)
+
+const DefaultCopyConcurrency = 4
+
+func (d *DufsVFS) GetCopyConcurrency() int {
+	if d.CopyConcurrency <= 0 {
+		return DefaultCopyConcurrency
+	}
+	return d.CopyConcurrency
+}
+
+// CopyFromFS
+// Uploads the tree under srcRoot of srcFS to dstRoot, directories are created as they are walked,
+// files are uploaded by up to CopyConcurrency goroutines. Failures do not stop the copy, they are joined in the returned error
+func (d *DufsVFS) CopyFromFS(srcFS fs.FS, srcRoot, dstRoot string) error {
+	var locker sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		locker.Lock()
+		errs = append(errs, err)
+		locker.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, d.GetCopyConcurrency())
+
+	err := fs.WalkDir(srcFS, srcRoot, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			fail(err)
+			return nil
+		}
+
+		rel := "."
+		if name != srcRoot {
+			rel = name[len(srcRoot)+1:]
+			if srcRoot == "." {
+				rel = name
+			}
+		}
+		dst := path.Join(dstRoot, rel)
+		if dst == "." {
+			dst = ""
+		}
+
+		if entry.IsDir() {
+			if dst == "" {
+				return nil
+			}
+			mkdir := d.Mkdir
+			if rel == "." {
+				mkdir = d.MkdirAll
+			}
+			err := mkdir(dst, fs.ModePerm)
+			if err != nil && !errors.Is(err, fs.ErrExist) {
+				fail(err)
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := d.copyFileFromFS(srcFS, name, dst)
+			if err != nil {
+				fail(&fs.PathError{Op: "copy", Path: name, Err: err})
+			}
+		}()
+
+		return nil
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (d *DufsVFS) copyFileFromFS(srcFS fs.FS, src, dst string) error {
+	file, err := srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	href, err := d.appendToRoot(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewDufsFile(d, dst, *href).ReadFromSized(file, stat.Size())
+	return err
+}
+
+// UploadDir
+// Uploads the tree under the local folder localDir to remoteDir, with the same relative paths, like CopyFromFS
+func (d *DufsVFS) UploadDir(localDir, remoteDir string) error {
+	return d.CopyFromFS(os.DirFS(localDir), ".", remoteDir)
+}
@@ -0,0 +1,136 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDufsCopyFromFS(t *testing.T) {
+	server := newFakeDufs(t)
+
+	src := fstest.MapFS{
+		"assets/index.html":       {Data: []byte("<html></html>")},
+		"assets/css/site.css":     {Data: []byte("body {}")},
+		"assets/img/logo.bin":     {Data: bytes.Repeat([]byte{7}, 100000)},
+		"assets/empty":            {Mode: fs.ModeDir},
+		"assets/deep/er/file.txt": {Data: []byte("deep")},
+		"other.txt":               {Data: []byte("not copied")},
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.CopyConcurrency = 2
+
+	err = dufs.CopyFromFS(src, "assets", "site/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, file := range src {
+		if name == "other.txt" || file.Mode.IsDir() {
+			continue
+		}
+		data, ok := server.get("site/static/" + name[len("assets/"):])
+		if !ok || !bytes.Equal(data, file.Data) {
+			t.Fatal(name, "should be copied")
+		}
+	}
+
+	if _, ok := server.get("site/static/other.txt"); ok {
+		t.Fatal("files outside of srcRoot should not be copied")
+	}
+
+	entries, err := dufs.ReadDir("site/static/empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("empty directory should be created empty, got", entries)
+	}
+
+	server.DenyUpload = true
+	dufs.EnforceCapabilities = true
+
+	err = dufs.CopyFromFS(src, ".", "denied")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("copy into a read-only server should fail with fs.ErrPermission, got", err)
+	}
+}
+
+func TestDufsUploadDir(t *testing.T) {
+	server := newFakeDufs(t)
+
+	local := t.TempDir()
+	files := map[string][]byte{
+		"readme.md":             []byte("# uploaded"),
+		"src/main.go":           []byte("package main"),
+		"src/pkg/lib.go":        []byte("package pkg"),
+		"assets/blob.bin":       bytes.Repeat([]byte{1, 2, 3}, 50000),
+		"assets/nested/a/b.txt": []byte("deep"),
+	}
+	for name, data := range files {
+		err := os.MkdirAll(filepath.Join(local, filepath.Dir(name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(local, name), data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.MkdirAll(filepath.Join(local, "empty"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.CopyConcurrency = 3
+
+	err = dufs.UploadDir(local, "backup/site")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		remote := "backup/site/" + name
+
+		stat, err := dufs.Stat(remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Size() != int64(len(data)) {
+			t.Fatal(remote, "should have size", len(data), "got", stat.Size())
+		}
+
+		uploaded, err := dufs.ReadFile(remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := Sha256(data)
+		if actual, _ := Sha256(uploaded); actual != expected {
+			t.Fatal(remote, "should have sha256", expected, "got", actual)
+		}
+	}
+
+	if !server.dirs["backup/site/empty"] {
+		t.Fatal("empty directories should be created")
+	}
+	if len(server.files) != len(files) {
+		t.Fatal("only the local files should be uploaded, got", len(server.files))
+	}
+
+	err = dufs.UploadDir(filepath.Join(local, "missing"), "backup/missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a missing local folder should fail with fs.ErrNotExist, got", err)
+	}
+}
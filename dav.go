@@ -0,0 +1,227 @@
+package vfs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const davPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/></D:prop></D:propfind>`
+
+const davProppatchMTime = `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:"><D:set><D:prop><D:getlastmodified>%s</D:getlastmodified></D:prop></D:set></D:propertyupdate>`
+
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// DavVFS
+// A VFS of a standard WebDAV server, files and directories are described with PROPFIND
+type DavVFS struct {
+	*HttpVFS
+
+	PathEncoder PathEncoder
+}
+
+func NewDavVFS(root string) (*DavVFS, error) {
+	root = strings.Trim(root, "/")
+
+	base, err := NewHttpVFS(root, "[dav]")
+	if err != nil {
+		return nil, err
+	}
+
+	dav := &DavVFS{
+		HttpVFS: base,
+	}
+
+	base.OpenFunc = func(name string) (fs.File, error) {
+		href, err := joinRoot(dav.Root, dav.GetPathEncoder(), name)
+		if err != nil {
+			return nil, err
+		}
+
+		return newListingFile(base, dav, name, *href), nil
+	}
+
+	return dav, nil
+}
+
+func (d *DavVFS) GetPathEncoder() PathEncoder {
+	if d.PathEncoder == nil {
+		return HierarchicalPathEncoder{}
+	}
+	return d.PathEncoder
+}
+
+func (d *DavVFS) statURL(href *URL, name string) (*HttpFileInfo, error) {
+	multistatus, err := d.propfind(href, "0")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range d.infos(multistatus, "") {
+		info.name = baseName(name)
+		return info, nil
+	}
+
+	return nil, fs.ErrNotExist
+}
+
+func (d *DavVFS) listURL(href *URL) ([]*HttpFileInfo, error) {
+	dir := *href.URL
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+		dir.RawPath = ""
+	}
+
+	multistatus, err := d.propfind(&URL{&dir}, "1")
+	if err != nil {
+		return nil, err
+	}
+
+	return d.infos(multistatus, dir.Path), nil
+}
+
+func (d *DavVFS) propfind(href *URL, depth string) (*davMultistatus, error) {
+	req, err := http.NewRequest("PROPFIND", href.String(), strings.NewReader(davPropfind))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	return d.multistatus(req, "Propfind")
+}
+
+// multistatus sends req and decodes its 207 response
+func (d *DavVFS) multistatus(req *http.Request, op string) (*davMultistatus, error) {
+	link := req.URL.String()
+
+	resp, err := d.Do(req)
+	if err != nil {
+		logFields(d.GetLogger(), op, "method", req.Method, "url", link, "error", err)
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	logFields(d.GetLogger(), op, "method", req.Method, "url", link, "status", resp.StatusCode)
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var multistatus davMultistatus
+	err = xml.Unmarshal(data, &multistatus)
+	if err != nil {
+		return nil, err
+	}
+
+	return &multistatus, nil
+}
+
+// Chtimes
+// Sets the modification time of name with a PROPPATCH of getlastmodified.
+// Many servers treat getlastmodified as protected, the failed propstat is then returned as an fs.ErrPermission
+func (d *DavVFS) Chtimes(name string, mtime time.Time) error {
+	href, err := joinRoot(d.Root, d.GetPathEncoder(), name)
+	if err != nil {
+		return err
+	}
+	return d.chtimesURL(href, name, mtime)
+}
+
+func (d *DavVFS) chtimesURL(href *URL, name string, mtime time.Time) error {
+	body := fmt.Sprintf(davProppatchMTime, mtime.UTC().Format(http.TimeFormat))
+
+	req, err := http.NewRequest("PROPPATCH", href.String(), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	multistatus, err := d.multistatus(req, "Proppatch")
+	if err != nil {
+		return err
+	}
+
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				return &fs.PathError{
+					Op:   "chtimes",
+					Path: name,
+					Err:  fmt.Errorf("dufs: getlastmodified not set: %s: %w", strings.TrimSpace(propstat.Status), fs.ErrPermission),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// infos converts the responses with a 200 propstat, named after the last segment of their href,
+// the response of the directory at skip, listed along with its children, is left out
+func (d *DavVFS) infos(multistatus *davMultistatus, skip string) []*HttpFileInfo {
+	root, _ := url.Parse(d.Root)
+
+	var infos []*HttpFileInfo
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+
+			href, err := url.Parse(strings.TrimSpace(response.Href))
+			if err != nil {
+				continue
+			}
+			if root != nil {
+				href = root.ResolveReference(href)
+			}
+			if skip != "" && strings.TrimSuffix(href.Path, "/") == strings.TrimSuffix(skip, "/") {
+				break
+			}
+
+			size, _ := strconv.ParseInt(strings.TrimSpace(propstat.Prop.ContentLength), 10, 64)
+			mtime := parseHTTPTime(propstat.Prop.LastModified)
+
+			infos = append(infos, &HttpFileInfo{
+				name:  d.GetPathEncoder().Decode(path.Base(strings.TrimSuffix(href.Path, "/"))),
+				size:  size,
+				mode:  fs.ModePerm,
+				mtime: mtime,
+				isDir: propstat.Prop.ResourceType.Collection != nil,
+			})
+			break
+		}
+	}
+
+	return infos
+}
@@ -0,0 +1,399 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// newFakeDav serves files, keyed by their path without leading slash, with the PROPFIND and GET of a WebDAV server,
+// directories are the parents of the files. A PROPPATCH of getlastmodified changes the mtime of a file
+func newFakeDav(t *testing.T, files map[string]string) *httptest.Server {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mtimes := map[string]time.Time{}
+	mtimeOf := func(name string) time.Time {
+		if modified, ok := mtimes[name]; ok {
+			return modified
+		}
+		return mtime
+	}
+
+	dirs := map[string]bool{"": true}
+	for name := range files {
+		for dir := parentOf(name); dir != ""; dir = parentOf(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	response := func(w io.Writer, name string) {
+		if dirs[name] {
+			href := "/" + name + "/"
+			if name == "" {
+				href = "/"
+			}
+			_, _ = fmt.Fprintf(w, `<D:response><D:href>%s</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype>`+
+				`<D:getlastmodified>%s</D:getlastmodified></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`,
+				href, mtimeOf(name).Format(http.TimeFormat))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `<D:response><D:href>/%s</D:href><D:propstat><D:prop><D:resourcetype/>`+
+			`<D:getcontentlength>%d</D:getcontentlength><D:getlastmodified>%s</D:getlastmodified></D:prop>`+
+			`<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`,
+			name, len(files[name]), mtimeOf(name).Format(http.TimeFormat))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(r.URL.Path, "/")
+		_, isFile := files[name]
+		if !isFile && !dirs[name] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case "PROPFIND":
+			body := bytes.NewBuffer(nil)
+			body.WriteString(`<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:">`)
+			response(body, name)
+			if r.Header.Get("Depth") == "1" && dirs[name] {
+				var children []string
+				for child := range files {
+					if parentOf(child) == name {
+						children = append(children, child)
+					}
+				}
+				for child := range dirs {
+					if child != "" && parentOf(child) == name {
+						children = append(children, child)
+					}
+				}
+				sort.Strings(children)
+				for _, child := range children {
+					response(body, child)
+				}
+			}
+			body.WriteString(`</D:multistatus>`)
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+			_, _ = w.Write(body.Bytes())
+		case "PROPPATCH":
+			var update struct {
+				LastModified string `xml:"set>prop>getlastmodified"`
+			}
+			err := xml.NewDecoder(r.Body).Decode(&update)
+			modified, parseErr := http.ParseTime(update.LastModified)
+			if err != nil || parseErr != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			mtimes[name] = modified
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:"><D:response><D:href>/%s</D:href>`+
+				`<D:propstat><D:prop><D:getlastmodified/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`, name)
+		case http.MethodGet, http.MethodHead:
+			http.ServeContent(w, r, name, mtimeOf(name), strings.NewReader(files[name]))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDavVFS(t *testing.T) {
+	files := map[string]string{
+		"readme.txt":        "read me",
+		"docs/a.txt":        "first document",
+		"docs/b.txt":        "second document",
+		"docs/nested/c.txt": "nested document",
+	}
+	server := newFakeDav(t, files)
+
+	dav, err := NewDavVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := dav.ReadDir("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, fmt.Sprintf("%s %t", entry.Name(), entry.IsDir()))
+	}
+	if strings.Join(names, ",") != "a.txt false,b.txt false,nested true" {
+		t.Fatal("unexpected entries of docs:", names)
+	}
+
+	info, err := entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(files["docs/a.txt"])) {
+		t.Fatal("a.txt should be", len(files["docs/a.txt"]), "bytes, got", info.Size())
+	}
+
+	data, err := dav.ReadFile("docs/nested/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != files["docs/nested/c.txt"] {
+		t.Fatal("unexpected content:", string(data))
+	}
+
+	stat, err := dav.Stat("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.IsDir() || stat.Name() != "docs" {
+		t.Fatal("docs should be a directory, got", stat.Name(), stat.IsDir())
+	}
+
+	_, err = dav.Stat("missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a missing file should fail with fs.ErrNotExist, got", err)
+	}
+
+	file, err := dav.Open("readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 2)
+	_, err = file.(io.ReaderAt).ReadAt(p, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "me" {
+		t.Fatal("ReadAt should read a range, got", string(p))
+	}
+
+	_, err = file.(io.Seeker).Seek(5, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != "me" {
+		t.Fatal("Read after Seek should read the rest, got", string(rest))
+	}
+}
+
+func TestDavVFS_Chtimes(t *testing.T) {
+	server := newFakeDav(t, map[string]string{"processed.txt": "done"})
+
+	dav, err := NewDavVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dav.Open("processed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC)
+	err = file.(*ListingFile).Chtimes(mtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.ModTime().Equal(before.ModTime()) || !after.ModTime().Equal(mtime) {
+		t.Fatal("the mtime of the open file should be", mtime, "got", after.ModTime())
+	}
+
+	mtime = mtime.Add(time.Hour)
+	err = dav.Chtimes("processed.txt", mtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := dav.Stat("processed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.ModTime().Equal(mtime) {
+		t.Fatal("the mtime should be", mtime, "got", stat.ModTime())
+	}
+
+	err = dav.Chtimes("missing.txt", mtime)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a missing file should fail with fs.ErrNotExist, got", err)
+	}
+
+	protected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:"><D:response><D:href>/a.txt</D:href>` +
+			`<D:propstat><D:prop><D:getlastmodified/></D:prop><D:status>HTTP/1.1 403 Forbidden</D:status></D:propstat></D:response></D:multistatus>`))
+	}))
+	defer protected.Close()
+
+	dav, err = NewDavVFS(protected.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dav.Chtimes("a.txt", mtime)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("a protected getlastmodified should fail with fs.ErrPermission, got", err)
+	}
+}
+
+// newWebDAV serves files from the memory file system of golang.org/x/net/webdav
+func newWebDAV(t *testing.T, files map[string]string) *httptest.Server {
+	ctx := context.Background()
+	memFS := webdav.NewMemFS()
+	for name, content := range files {
+		dir := ""
+		for _, segment := range strings.Split(path.Dir(name), "/") {
+			if segment == "." {
+				break
+			}
+			dir += "/" + segment
+			err := memFS.Mkdir(ctx, dir, 0755)
+			if err != nil && !errors.Is(err, fs.ErrExist) {
+				t.Fatal(err)
+			}
+		}
+
+		file, err := memFS.OpenFile(ctx, "/"+name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.WriteString(file, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = file.Close()
+	}
+
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: memFS,
+		LockSystem: webdav.NewMemLS(),
+	})
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDavVFS_WebDAV(t *testing.T) {
+	files := map[string]string{
+		"readme.txt":              "read me",
+		"docs/a.txt":              "first document",
+		"docs/b c.txt":            "second document",
+		"docs/nested/deep.txt":    "nested document",
+		"docs/nested/empty/x.txt": "",
+	}
+	server := newWebDAV(t, files)
+
+	dav, err := NewDavVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := dav.ReadDir("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, fmt.Sprintf("%s %t", entry.Name(), entry.IsDir()))
+	}
+	if strings.Join(names, ",") != "a.txt false,b c.txt false,nested true" {
+		t.Fatal("unexpected entries of docs:", names)
+	}
+
+	info, err := entries[1].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(files["docs/b c.txt"])) || info.ModTime().IsZero() {
+		t.Fatal("the size and mtime of the listing should be parsed, got", info.Size(), info.ModTime())
+	}
+
+	for name, content := range files {
+		data, err := dav.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatal(name, "should read", content, "got", string(data))
+		}
+	}
+
+	stat, err := dav.Stat("docs/nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.IsDir() || stat.Name() != "nested" {
+		t.Fatal("docs/nested should be a directory, got", stat.Name(), stat.IsDir())
+	}
+
+	_, err = dav.Stat("missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a missing file should fail with fs.ErrNotExist, got", err)
+	}
+
+	file, err := dav.Open("readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 2)
+	_, err = file.(io.ReaderAt).ReadAt(p, 5)
+	if err != nil || string(p) != "me" {
+		t.Fatal("ReadAt should read a range, got", string(p), err)
+	}
+
+	// x/net/webdav refuses to set the live getlastmodified property
+	err = dav.Chtimes("readme.txt", time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC))
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("a refused PROPPATCH should fail with fs.ErrPermission, got", err)
+	}
+}
+
+func TestDavVFSNumericZone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:">`+
+			`<D:response><D:href>/zoned.txt</D:href><D:propstat><D:prop><D:resourcetype/>`+
+			`<D:getcontentlength>5</D:getcontentlength><D:getlastmodified>Tue, 02 Jan 2024 04:04:05 +0100</D:getlastmodified></D:prop>`+
+			`<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`)
+	}))
+	defer server.Close()
+
+	dav, err := NewDavVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := dav.Stat("zoned.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.ModTime().Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatal("a getlastmodified with a numeric zone should be parsed, got", stat.ModTime())
+	}
+}
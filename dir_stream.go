@@ -0,0 +1,207 @@
+package vfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"net/http"
+	"slices"
+)
+
+// DirStreamEntry is either an entry of the listing or the error that ended it
+type DirStreamEntry struct {
+	Entry fs.DirEntry
+	Err   error
+}
+
+// ReadDirStream
+// Sends the entries of name while its index is still downloading, so huge directories are not held in memory.
+// The channel is always closed once the listing ends, fails or ctx is done,
+// a consumer that stops reading early must cancel ctx to release the connection
+func (d *DufsVFS) ReadDirStream(ctx context.Context, name string) <-chan DirStreamEntry {
+	entries := make(chan DirStreamEntry)
+
+	go func() {
+		defer close(entries)
+
+		send := func(entry DirStreamEntry) bool {
+			select {
+			case entries <- entry:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		err := d.streamDir(ctx, name, func(entry fs.DirEntry) bool {
+			return send(DirStreamEntry{Entry: entry})
+		})
+		if err != nil && ctx.Err() == nil {
+			send(DirStreamEntry{Err: err})
+		}
+	}()
+
+	return entries
+}
+
+// Entries
+// Yields the entries of name as they are decoded, like ReadDirStream but without a goroutine,
+// breaking out of the loop closes the response. An error ending the listing is yielded last, with a nil entry
+func (d *DufsVFS) Entries(name string) iter.Seq2[fs.DirEntry, error] {
+	return func(yield func(fs.DirEntry, error) bool) {
+		err := d.streamDir(context.Background(), name, func(entry fs.DirEntry) bool {
+			return yield(entry, nil)
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// streamDir calls fn for each entry of name as it is decoded, until fn returns false
+func (d *DufsVFS) streamDir(ctx context.Context, name string, fn func(entry fs.DirEntry) bool) error {
+	href, err := d.appendToRoot(name)
+	if err != nil {
+		return err
+	}
+
+	file := NewDufsFile(d, name, *href)
+
+	resp, err := file.jsonContext(ctx, http.MethodGet, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if !file.determineIsDir(resp) {
+		if !file.determineIsHTMLListing(resp) {
+			return fs.ErrInvalid
+		} else if !d.HTMLListing {
+			return ErrNoJSONListing
+		}
+		entries, err := file.readHTMLDir(resp, -1)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !fn(entry) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	return decodePaths(json.NewDecoder(resp.Body), func(path DufsJSONFile) bool {
+		return fn(file.dirEntry(path))
+	})
+}
+
+// readDirPage implements ReadDir(n) over pending, the entries listed but not returned yet,
+// then next, which decodes the following ones until ok is false, nil if pending is the whole listing.
+// n <= 0 returns the rest with the entries read before a failure, n > 0 at most n entries, or io.EOF once over
+func readDirPage[T any](pending *[]T, n int, next func() (entry T, err error, ok bool)) ([]T, error) {
+	if next == nil {
+		next = func() (entry T, err error, ok bool) {
+			return entry, nil, false
+		}
+	}
+
+	if n <= 0 {
+		entries := *pending
+		*pending = nil
+		for {
+			entry, err, ok := next()
+			if err != nil {
+				return entries, err
+			} else if !ok {
+				return entries, nil
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	entries := slices.Clip((*pending)[:min(n, len(*pending))])
+	*pending = (*pending)[len(entries):]
+	for len(entries) < n {
+		entry, err, ok := next()
+		if err != nil {
+			return entries, err
+		} else if !ok {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+// decodePaths decodes the "paths" of a DufsJSONIndex one by one, skipping the other fields
+func decodePaths(decoder *json.Decoder, fn func(path DufsJSONFile) bool) error {
+	err := expectDelim(decoder, '{')
+	if err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if key != "paths" {
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		} else if token == nil {
+			continue
+		} else if token != json.Delim('[') {
+			return fmt.Errorf("dufs: unexpected %v in paths", token)
+		}
+
+		for decoder.More() {
+			var path DufsJSONFile
+			err = decoder.Decode(&path)
+			if err != nil {
+				return err
+			}
+			if !fn(path) {
+				return nil
+			}
+		}
+
+		err = expectDelim(decoder, ']')
+		if err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(decoder, '}')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return errors.New("dufs: malformed index, expected " + delim.String())
+	}
+	return nil
+}
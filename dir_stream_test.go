@@ -0,0 +1,366 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamGoroutines counts the goroutines still running a ReadDirStream
+func streamGoroutines() int {
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	return strings.Count(stacks, "(*DufsVFS).ReadDirStream")
+}
+
+func TestDufsReadDirStream(t *testing.T) {
+	server := newFakeDufs(t)
+	for i := 0; i < 2000; i++ {
+		server.put(fmt.Sprintf("many/%04d.txt", i), []byte("x"))
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for entry := range dufs.ReadDirStream(context.Background(), "many") {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		if entry.Entry.Name() != fmt.Sprintf("%04d.txt", count) {
+			t.Fatal("unexpected entry", entry.Entry.Name(), "at", count)
+		}
+		count++
+	}
+	if count != 2000 {
+		t.Fatal("stream should list 2000 entries, got", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries := dufs.ReadDirStream(ctx, "many")
+	for i := 0; i < 10; i++ {
+		entry := <-entries
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+	}
+	cancel()
+
+	for entry := range entries {
+		if entry.Err != nil {
+			t.Fatal("a cancelled stream should not report an error, got", entry.Err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for streamGoroutines() > 0 || len(dufs.ActiveOperations()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("cancelled stream leaked", streamGoroutines(), "goroutines and", len(dufs.ActiveOperations()), "open bodies")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for entry := range dufs.ReadDirStream(context.Background(), "missing") {
+		if entry.Err == nil {
+			t.Fatal("listing a missing directory should fail")
+		}
+	}
+}
+
+func TestDufsChunkedListing(t *testing.T) {
+	const count = 20000
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write([]byte(`{"href":"/big/","kind":"Index","allow_upload":true,"paths":[`))
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				_, _ = w.Write([]byte(","))
+			}
+			_, _ = fmt.Fprintf(w, `{"path_type":"File","name":"%05d.txt","mtime":0,"size":%d}`, i, i)
+			if i%1000 == 0 {
+				w.(http.Flusher).Flush()
+			}
+		}
+		_, _ = w.Write([]byte(`],"allow_delete":true}`))
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := file.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != count || entries[count-1].Name() != fmt.Sprintf("%05d.txt", count-1) {
+		t.Fatal("ReadDir should list", count, "entries, got", len(entries))
+	}
+
+	file, err = dufs.Open("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = file.(fs.ReadDirFile).ReadDir(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 10 {
+		t.Fatal("ReadDir(10) should list 10 entries, got", len(entries))
+	}
+	_ = file.Close()
+
+	streamed := 0
+	for entry := range dufs.ReadDirStream(context.Background(), "big") {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		info, err := entry.Entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(streamed) {
+			t.Fatal("entry", streamed, "should have size", streamed, "got", info.Size())
+		}
+		streamed++
+	}
+	if streamed != count {
+		t.Fatal("ReadDirStream should list", count, "entries, got", streamed)
+	}
+}
+
+func TestDufsEntries(t *testing.T) {
+	server := newFakeDufs(t)
+	for i := 0; i < 2000; i++ {
+		server.put(fmt.Sprintf("many/%04d.txt", i), []byte("x"))
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for entry, err := range dufs.Entries("many") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Name() != fmt.Sprintf("%04d.txt", count) {
+			t.Fatal("unexpected entry", entry.Name(), "at", count)
+		}
+		count++
+	}
+	if count != 2000 {
+		t.Fatal("Entries should yield 2000 entries, got", count)
+	}
+
+	var first fs.DirEntry
+	for entry, err := range dufs.Entries("many") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if operations := dufs.ActiveOperations(); len(operations) != 1 {
+			t.Fatal("the listing should be open while iterating, got", operations)
+		}
+		first = entry
+		break
+	}
+	if first == nil || first.Name() != "0000.txt" {
+		t.Fatal("the first entry should be 0000.txt, got", first)
+	}
+	if operations := dufs.ActiveOperations(); len(operations) != 0 {
+		t.Fatal("breaking out of the loop should close the body, got", operations)
+	}
+
+	failed := 0
+	for entry, err := range dufs.Entries("missing") {
+		if entry != nil || !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal("a missing directory should yield fs.ErrNotExist, got", entry, err)
+		}
+		failed++
+	}
+	if failed != 1 {
+		t.Fatal("the error should be yielded once, got", failed)
+	}
+}
+
+func TestDufsReadDirPages(t *testing.T) {
+	server := newFakeDufs(t)
+	for i := 0; i < 25; i++ {
+		server.put(fmt.Sprintf("pages/%02d.txt", i), []byte("x"))
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, expected := range []int{10, 10, 5} {
+		entries, err := file.(fs.ReadDirFile).ReadDir(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != expected {
+			t.Fatal("ReadDir(10) should list", expected, "entries, got", len(entries))
+		}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) != 25 || names[0] != "00.txt" || names[24] != "24.txt" {
+		t.Fatal("the pages should list every entry once, got", names)
+	}
+
+	entries, err := file.(fs.ReadDirFile).ReadDir(10)
+	if err != io.EOF || len(entries) != 0 {
+		t.Fatal("the listing should be over, got", entries, err)
+	}
+	if operations := dufs.ActiveOperations(); len(operations) != 0 {
+		t.Fatal("the listing should be closed once over, got", operations)
+	}
+
+	file, err = dufs.Open("pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(fs.ReadDirFile).ReadDir(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operations := dufs.ActiveOperations(); len(operations) != 1 {
+		t.Fatal("the rest of the listing should be left to decode, got", operations)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operations := dufs.ActiveOperations(); len(operations) != 0 {
+		t.Fatal("Close should close the listing, got", operations)
+	}
+}
+
+func TestDufsReadDirTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write([]byte(`{"href":"/cut/","kind":"Index","paths":[` +
+			`{"path_type":"File","name":"a.txt","mtime":0,"size":1},` +
+			`{"path_type":"File","name":"b.txt","mtime":0,"size":1},` +
+			`{"path_type":`))
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("cut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	entries, err := file.(fs.ReadDirFile).ReadDir(-1)
+	if err == nil {
+		t.Fatal("a truncated listing should fail")
+	}
+	if len(entries) != 2 {
+		t.Fatal("ReadDir(-1) should return the entries decoded before the failure, got", entries)
+	}
+}
+
+// BenchmarkDufsReadDir compares the buffered decoding of a 50k entries index with ReadDir decoding it as it arrives
+func BenchmarkDufsReadDir(b *testing.B) {
+	const count = 50000
+
+	index := bytes.NewBuffer(nil)
+	index.WriteString(`{"href":"/big/","kind":"Index","allow_upload":true,"paths":[`)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			index.WriteString(",")
+		}
+		_, _ = fmt.Fprintf(index, `{"path_type":"File","name":"%05d.txt","mtime":0,"size":%d}`, i, i)
+	}
+	index.WriteString(`],"allow_delete":true}`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(index.Bytes())
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	dufs.Logger = log.New(io.Discard, "", 0)
+
+	b.Run("ReadAll+Unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resp, err := http.Get(server.URL + "/big?json")
+			if err != nil {
+				b.Fatal(err)
+			}
+			data, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+			var index DufsJSONIndex
+			err = json.Unmarshal(data, &index)
+			if err != nil || len(index.Paths) != count {
+				b.Fatal("unexpected index", len(index.Paths), err)
+			}
+		}
+	})
+
+	for _, n := range []int{-1, 100} {
+		b.Run(fmt.Sprintf("ReadDir(%d)", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				file, err := dufs.Open("big")
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = file.(fs.ReadDirFile).ReadDir(n)
+				_ = file.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package vfs
+
+import (
+	"io/fs"
+	"strings"
+)
+
+type DiskUsageOpts struct {
+	// MaxDepth is how many levels below dir are counted, the directories at MaxDepth are counted but not listed, 0 is unlimited
+	MaxDepth int
+}
+
+// DiskUsage
+// Returns the total size of the files under dir, with the number of files and directories, dir excluded.
+// The sizes come from the listings, so no file is requested on its own
+func (d *DufsVFS) DiskUsage(dir string) (totalBytes int64, fileCount int, dirCount int, err error) {
+	return d.DiskUsageWithOpts(dir, DiskUsageOpts{})
+}
+
+// DiskUsageWithOpts
+// Same as DiskUsage, a directory which can not be listed, or an entry which can not be described,
+// is skipped with a warning, only a failure to describe dir itself is returned
+func (d *DufsVFS) DiskUsageWithOpts(dir string, opts DiskUsageOpts) (totalBytes int64, fileCount int, dirCount int, err error) {
+	rootDepth := depthOf(dir)
+
+	err = d.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if entry == nil {
+				return err
+			}
+			logFields(d.GetLogger(), "Skip disk usage", "name", name, "error", err)
+			return fs.SkipDir
+		}
+
+		depth := depthOf(name) - rootDepth
+		if depth == 0 {
+			return nil
+		}
+
+		if entry.IsDir() {
+			dirCount++
+			if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			logFields(d.GetLogger(), "Skip disk usage", "name", name, "error", err)
+			return nil
+		}
+
+		totalBytes += info.Size()
+		fileCount++
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return totalBytes, fileCount, dirCount, nil
+}
+
+// depthOf is the number of segments of name, 0 for the root
+func depthOf(name string) int {
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return 0
+	}
+	return strings.Count(name, "/") + 1
+}
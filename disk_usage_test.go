@@ -0,0 +1,57 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestDufsDiskUsage(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("quota/a.txt", []byte("12345"))
+	server.put("quota/docs/b.txt", []byte("1234567890"))
+	server.put("quota/docs/c.txt", []byte("123"))
+	server.put("quota/docs/deep/d.txt", []byte("1234567"))
+	server.put("outside.txt", []byte("not counted"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := server.Requests.Load()
+
+	totalBytes, fileCount, dirCount, err := dufs.DiskUsage("quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totalBytes != 25 || fileCount != 4 || dirCount != 2 {
+		t.Fatal("expected 25 bytes in 4 files and 2 directories, got", totalBytes, fileCount, dirCount)
+	}
+
+	// a Stat of quota, then a listing of quota, docs and deep
+	if requests := server.Requests.Load() - before; requests != 4 {
+		t.Fatal("the sizes should come from the listings, got", requests, "requests")
+	}
+
+	totalBytes, fileCount, dirCount, err = dufs.DiskUsageWithOpts("quota", DiskUsageOpts{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totalBytes != 5 || fileCount != 1 || dirCount != 1 {
+		t.Fatal("expected 5 bytes in 1 file and 1 directory at depth 1, got", totalBytes, fileCount, dirCount)
+	}
+
+	totalBytes, fileCount, dirCount, err = dufs.DiskUsage("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totalBytes != 36 || fileCount != 5 || dirCount != 3 {
+		t.Fatal("expected 36 bytes in 5 files and 3 directories under the root, got", totalBytes, fileCount, dirCount)
+	}
+
+	_, _, _, err = dufs.DiskUsage("missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a missing directory should fail with fs.ErrNotExist, got", err)
+	}
+}
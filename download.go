@@ -0,0 +1,225 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DownloadTo
+// Downloads the file into w with parts concurrent Range requests, up to CopyConcurrency at once,
+// each written at its offset. A single GET is used if parts is below 2, or the server does not send Accept-Ranges: bytes
+func (d *DufsFile) DownloadTo(w io.WriterAt, parts int) (int64, error) {
+	resp, err := d.head(d.getContext())
+	if err != nil {
+		return 0, err
+	}
+
+	stat, err := d.cacheStat(resp)
+	if err != nil {
+		return 0, err
+	}
+	if stat.IsDir() {
+		return 0, &fs.PathError{Op: "download", Path: d.Name, Err: fs.ErrInvalid}
+	}
+
+	size := stat.Size()
+	if parts < 2 || size < int64(parts) || resp.Header.Get("Accept-Ranges") != "bytes" {
+		// a Clone has no stream open, so WriteTo downloads the whole file
+		return d.Clone().WriteTo(io.NewOffsetWriter(w, 0))
+	}
+
+	ctx, cancel := context.WithCancel(d.getContext())
+	defer cancel()
+	file := d.WithContext(ctx)
+
+	partSize := (size + int64(parts) - 1) / int64(parts)
+
+	var (
+		locker  sync.Mutex
+		errs    []error
+		written int64
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, min(parts, d.vfs.GetCopyConcurrency()))
+
+	for start := int64(0); start < size; start += partSize {
+		end := min(start+partSize, size) - 1
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			n, err := file.Clone().downloadRange(io.NewOffsetWriter(w, start), start, end)
+
+			locker.Lock()
+			written += n
+			if err != nil {
+				errs = append(errs, err)
+				cancel()
+			}
+			locker.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return written, errors.Join(errs...)
+	}
+
+	return written, nil
+}
+
+// downloadRange copies the bytes from start to end, both included, into w
+func (d *DufsFile) downloadRange(w io.Writer, start, end int64) (int64, error) {
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	d.setIfRange(header)
+
+	resp, err := d.get(d.getContext(), header)
+	if err != nil {
+		return 0, d.contextErr(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	err = d.checkUnchanged(resp)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, errors.New("dufs: server ignored the Range header")
+	}
+
+	n, err := io.Copy(w, io.LimitReader(resp.Body, end-start+1))
+	if err == nil && n != end-start+1 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, d.contextErr(err)
+}
+
+// DownloadDir
+// Downloads the tree under remoteDir into the local folder localDir, directories are created as they are walked,
+// files are downloaded by up to CopyConcurrency goroutines. A local file with the size of the remote one is skipped,
+// so a failed download is resumed by calling it again. Failures do not stop the download, they are joined in the returned error
+func (d *DufsVFS) DownloadDir(remoteDir, localDir string) error {
+	root, err := normalizeName(remoteDir)
+	if err != nil {
+		return err
+	}
+
+	var locker sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		locker.Lock()
+		errs = append(errs, err)
+		locker.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, d.GetCopyConcurrency())
+
+	err = d.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if entry == nil {
+				return err
+			}
+			fail(err)
+			return nil
+		}
+
+		// name is joined with the names of a listing sent by the server, which must not lead out of localDir
+		rel, ok := "", true
+		switch {
+		case name == root:
+		case root == "":
+			rel = name
+		case strings.HasPrefix(name, root+"/"):
+			rel = name[len(root)+1:]
+		default:
+			ok = false
+		}
+		if !ok || rel == "." || rel != "" && !filepath.IsLocal(filepath.FromSlash(rel)) {
+			fail(&fs.PathError{Op: "download", Path: name, Err: fs.ErrInvalid})
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		dst := filepath.Join(localDir, filepath.FromSlash(rel))
+
+		if entry.IsDir() {
+			err := os.MkdirAll(dst, 0755)
+			if err != nil {
+				fail(err)
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			fail(err)
+			return nil
+		}
+		if local, err := os.Stat(dst); err == nil && local.Mode().IsRegular() && local.Size() == info.Size() {
+			return nil
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := d.downloadFile(name, dst)
+			if err != nil {
+				fail(&fs.PathError{Op: "download", Path: name, Err: err})
+			}
+		}()
+
+		return nil
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// downloadFile streams the remote file src into the local file dst, replacing it
+func (d *DufsVFS) downloadFile(src, dst string) error {
+	href, err := d.appendToRoot(src)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewDufsFile(d, src, *href).WriteTo(file)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
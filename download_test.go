@@ -0,0 +1,245 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDufsDownloadTo(t *testing.T) {
+	server := newFakeDufs(t)
+
+	hash, filename, data, err := CreateTestData()
+	t.Cleanup(func() {
+		_ = os.Remove(path.Join(TestDataFolder, string(filename)))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.put("large.bin", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parts := range []int{1, 3, 8} {
+		file, err := dufs.Open("large.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dst, err := os.CreateTemp(t.TempDir(), "download-*.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := file.(*DufsFile).DownloadTo(dst, parts)
+		_ = dst.Close()
+		if err != nil {
+			t.Fatal(parts, err)
+		}
+		if n != int64(len(data)) {
+			t.Fatal(parts, "parts should download", len(data), "bytes, got", n)
+		}
+
+		downloaded, err := os.ReadFile(dst.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, _ := Sha256(downloaded); actual != hash {
+			t.Fatal(parts, "parts should download sha256", hash, "got", actual)
+		}
+	}
+}
+
+func TestDufsDownloadToWithoutRanges(t *testing.T) {
+	content := bytes.Repeat([]byte("no ranges"), 1000)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Range") != "" {
+			t.Error("Range should not be sent without Accept-Ranges")
+		}
+		w.Header().Set("Content-Disposition", "attachment")
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := os.CreateTemp(t.TempDir(), "download-*.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = dst.Close()
+	}()
+
+	_, err = file.(*DufsFile).DownloadTo(dst, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := os.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Fatal("fallback download mismatch, got", len(downloaded), "bytes")
+	}
+	if requests != 2 {
+		t.Fatal("fallback should take a HEAD and a GET, got", requests, "requests")
+	}
+}
+
+func TestDufsDownloadDir(t *testing.T) {
+	server := newFakeDufs(t)
+	files := map[string][]byte{
+		"site/index.html":        []byte("<html></html>"),
+		"site/css/site.css":      []byte("body {}"),
+		"site/img/logo.bin":      bytes.Repeat([]byte{9, 8, 7}, 40000),
+		"site/deep/er/notes.txt": []byte("deep"),
+		"elsewhere.txt":          []byte("not downloaded"),
+	}
+	for name, data := range files {
+		server.put(name, data)
+	}
+	server.mkdir("site/empty")
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.CopyConcurrency = 2
+
+	local := t.TempDir()
+	err = dufs.DownloadDir("site", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		if !strings.HasPrefix(name, "site/") {
+			continue
+		}
+		downloaded, err := os.ReadFile(filepath.Join(local, filepath.FromSlash(strings.TrimPrefix(name, "site/"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := Sha256(data)
+		if actual, _ := Sha256(downloaded); actual != expected {
+			t.Fatal(name, "should have sha256", expected, "got", actual)
+		}
+	}
+	if stat, err := os.Stat(filepath.Join(local, "empty")); err != nil || !stat.IsDir() {
+		t.Fatal("empty directories should be created, got", err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "elsewhere.txt")); !os.IsNotExist(err) {
+		t.Fatal("files outside of the remote directory should not be downloaded")
+	}
+
+	// a local file of the remote size is considered downloaded, a partial one is downloaded again
+	err = os.WriteFile(filepath.Join(local, "index.html"), []byte("<HTML></HTML>"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(local, "img", "logo.bin"), []byte{9, 8}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := server.Requests.Load()
+	err = dufs.DownloadDir("site", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the Stat and the 6 listings of the tree, then a GET of logo.bin
+	if requests := server.Requests.Load() - before; requests != 8 {
+		t.Fatal("only the partial file should be downloaded again, got", requests, "requests")
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "index.html")); string(data) != "<HTML></HTML>" {
+		t.Fatal("a file of the remote size should be skipped, got", string(data))
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "img", "logo.bin")); !bytes.Equal(data, files["site/img/logo.bin"]) {
+		t.Fatal("a partial file should be downloaded again, got", len(data), "bytes")
+	}
+
+	err = dufs.DownloadDir("missing", local)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a missing remote directory should fail with fs.ErrNotExist, got", err)
+	}
+}
+
+func TestDufsDownloadDirEscape(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("site/index.html", []byte("<html></html>"))
+
+	// the listings name entries outside of their directory
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := httptest.NewRecorder()
+		server.Config.Handler.ServeHTTP(recorder, r)
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+
+		body := recorder.Body.Bytes()
+		if r.Method == http.MethodGet && strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+			var index DufsJSONIndex
+			if err := json.Unmarshal(body, &index); err == nil {
+				for _, name := range []string{"../outside.txt", ".."} {
+					index.Paths = append(index.Paths, DufsJSONFile{PathType: "File", Name: name, Size: 7})
+				}
+				body, _ = json.Marshal(index)
+				w.Header().Del("Content-Length")
+			}
+		}
+
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(body)
+	}))
+	defer proxy.Close()
+
+	dufs, err := NewDufsVFS(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, remote := range []string{"site", ""} {
+		parent := t.TempDir()
+		local := filepath.Join(parent, "local")
+
+		err = dufs.DownloadDir(remote, local)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Fatal("entries leading out of", remote, "should fail with fs.ErrInvalid, got", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(parent, "outside.txt")); !os.IsNotExist(err) {
+			t.Fatal("nothing should be written outside of the local directory of", remote)
+		}
+		index := filepath.Join(local, "index.html")
+		if remote == "" {
+			index = filepath.Join(local, "site", "index.html")
+		}
+		if data, err := os.ReadFile(index); err != nil || string(data) != "<html></html>" {
+			t.Fatal("the other entries of", remote, "should still be downloaded, got", string(data), err)
+		}
+	}
+}
@@ -2,16 +2,29 @@ package vfs
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"iter"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrNoJSONListing = errors.New("dufs: server responded with an HTML listing instead of JSON")
+	ErrFileChanged   = errors.New("dufs: file changed since its last Stat")
+	ErrUnauthorized  = errors.New("dufs: unauthorized")
 )
 
 type PathType string
@@ -22,6 +35,45 @@ const (
 	PathTypeDir PathType = "Dir"
 )
 
+// MTimeUnit is the unit of DufsJSONFile.MTime, dufs reports milliseconds
+type MTimeUnit int
+
+const (
+	MTimeUnitMillisecond MTimeUnit = iota
+	MTimeUnitSecond
+	MTimeUnitMicrosecond
+	MTimeUnitNanosecond
+	// MTimeUnitAuto guesses the unit from the magnitude, assuming the time is between 1973 and 5138
+	MTimeUnitAuto
+)
+
+func (d MTimeUnit) Time(mtime int64) time.Time {
+	unit := d
+	if unit == MTimeUnitAuto {
+		switch abs := max(mtime, -mtime); {
+		case abs < 1e11:
+			unit = MTimeUnitSecond
+		case abs < 1e14:
+			unit = MTimeUnitMillisecond
+		case abs < 1e17:
+			unit = MTimeUnitMicrosecond
+		default:
+			unit = MTimeUnitNanosecond
+		}
+	}
+
+	switch unit {
+	case MTimeUnitSecond:
+		return time.Unix(mtime, 0)
+	case MTimeUnitMicrosecond:
+		return time.UnixMicro(mtime)
+	case MTimeUnitNanosecond:
+		return time.Unix(0, mtime)
+	default:
+		return time.UnixMilli(mtime)
+	}
+}
+
 type DufsJSONIndex struct {
 	Href         string         `json:"href"`
 	Kind         string         `json:"kind"`
@@ -45,6 +97,78 @@ type DufsJSONFile struct {
 
 type DufsVFS struct {
 	*HttpVFS
+
+	PathEncoder PathEncoder
+	MTimeUnit   MTimeUnit
+
+	// ProbePath is the file downloaded by Probe to estimate the bandwidth, the root index is used if empty
+	ProbePath string
+	// ProbeSize is the max bytes downloaded by Probe, DefaultProbeSize is used if 0
+	ProbeSize int64
+
+	// HTMLListing makes ReadDir scrape the HTML index of servers without ?json support, instead of failing with ErrNoJSONListing
+	HTMLListing bool
+
+	// SmallFileThreshold is the size from which uploads are sent as a PUT of an empty file followed by a PATCH per PartSize,
+	// so a failed part can be retried alone. Smaller uploads are a single PUT, as are all uploads if it is 0
+	SmallFileThreshold int64
+	// PartSize is the size of the PATCH requests of an upload over SmallFileThreshold, DefaultPartSize if 0
+	PartSize int64
+
+	// EnforceCapabilities fails operations with ErrUploadNotAllowed or ErrNotPermitted, without sending them,
+	// when the allow_upload, allow_delete or allow_search flag of their directory is off.
+	// It costs a request per directory and CapabilityTTL, but an upload is rejected before its body is read
+	EnforceCapabilities bool
+	// CapabilityTTL is how long the flags of a directory are cached, DefaultCapabilityTTL if 0
+	CapabilityTTL time.Duration
+
+	capabilities capabilityCache
+
+	// CopyConcurrency is the number of files uploaded at once by CopyFromFS, DefaultCopyConcurrency if 0
+	CopyConcurrency int
+
+	// VerifyWrites makes WriteAt Stat the file after each PATCH and fail with io.ErrShortWrite
+	// if it does not reach the end of the written range, such as when a proxy truncated the body
+	VerifyWrites bool
+
+	// StatTTL is how long CachedStat trusts the last Stat of a file, forever if 0
+	StatTTL time.Duration
+
+	// KeepTrailingEmptyLine makes ReadLines return an empty last line for a file ending with a newline
+	KeepTrailingEmptyLine bool
+
+	// TarDirectories makes WriteTo on a directory stream a tar archive of its tree instead of the JSON index
+	TarDirectories bool
+
+	// EnableGzip asks for gzip with the GETs reading a whole file or listing, and decompresses the responses,
+	// whose Content-Length is then unknown. Ranged reads are always sent as identity
+	EnableGzip bool
+
+	// ReadCacheSize is the size up to which a file is kept in memory by its handle on the first read,
+	// and only downloaded again when a GET with If-None-Match is not answered 304 Not Modified. 0 disables it
+	ReadCacheSize int64
+
+	// UploadProgress is called as the body of ReadFrom and WriteAt is sent, with the bytes sent by the call so far
+	// and its total, -1 if unknown. A retried upload starts again from where its body is rewound
+	UploadProgress ProgressFunc
+
+	// AppendFlushSize is the size buffered by an Appender before it sends a PATCH, DefaultAppendFlushSize if 0
+	AppendFlushSize int
+
+	// SpillMemorySize is the size up to which the copy of a file read from a server ignoring Range is kept in memory,
+	// a larger file or one of unknown size is copied to a temporary file, DefaultSpillMemorySize if 0
+	SpillMemorySize int64
+}
+
+// NewDufsVFSWithAuth
+// Same as NewDufsVFS, with every request sent with basic auth
+func NewDufsVFSWithAuth(root, username, password string) (*DufsVFS, error) {
+	dufs, err := NewDufsVFS(root)
+	if err != nil {
+		return nil, err
+	}
+	dufs.SetBasicAuth(username, password)
+	return dufs, nil
 }
 
 func NewDufsVFS(root string) (*DufsVFS, error) {
@@ -59,7 +183,13 @@ func NewDufsVFS(root string) (*DufsVFS, error) {
 		HttpVFS: base,
 	}
 
-	base.OpenFunc = func(name string) (fs.File, error) {
+	base.OpenFunc = func(name string) (_ fs.File, err error) {
+		// nothing is sent until the file is used, the span records the name being resolved
+		_, span := dufs.startSpan(context.Background(), "Open", name)
+		defer func() {
+			span.End(err)
+		}()
+
 		href, err := dufs.appendToRoot(name)
 		if err != nil {
 			return nil, err
@@ -75,34 +205,109 @@ func NewDufsVFS(root string) (*DufsVFS, error) {
 	return dufs, nil
 }
 
+func (d *DufsVFS) GetPathEncoder() PathEncoder {
+	if d.PathEncoder == nil {
+		return HierarchicalPathEncoder{}
+	}
+	return d.PathEncoder
+}
+
+// normalizeName drops the empty and "." segments of name, so "/a//./b/" becomes "a/b" and "" or "/" the root,
+// and rejects names with a ".." segment or invalid UTF-8
+func normalizeName(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", &fs.PathError{Op: "normalize", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(name, "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			return "", &fs.PathError{Op: "normalize", Path: name, Err: fs.ErrInvalid}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
 func (d *DufsVFS) appendToRoot(name string) (*URL, error) {
-	u, err := url.Parse(d.Root)
+	return joinRoot(d.Root, d.GetPathEncoder(), name)
+}
+
+// joinRoot returns the URL of name under root, a name starting with "/" is given a trailing slash,
+// the segments are escaped by encoder and kept as RawPath, so a "#", "?" or "%" in a name stays in the path
+func joinRoot(root string, encoder PathEncoder, name string) (*URL, error) {
+	u, err := url.Parse(root)
 	if err != nil {
 		return nil, err
 	}
 
-	var segments []string
-	for _, s := range strings.Split(name, "/") {
-		if s != "" {
-			segments = append(segments, s)
-		}
+	normalized, err := normalizeName(name)
+	if err != nil {
+		return nil, err
 	}
 
-	u.Path = strings.Trim(u.Path, "/") + "/" + strings.Join(segments, "/")
+	rawPath := strings.Trim(u.EscapedPath(), "/") + "/" + encoder.Encode(normalized)
 
-	if strings.HasPrefix(name, "/") && !strings.HasSuffix(u.Path, "/") {
-		u.Path += "/"
+	if strings.HasPrefix(name, "/") && !strings.HasSuffix(rawPath, "/") {
+		rawPath += "/"
+	}
+
+	u.Path, err = url.PathUnescape(rawPath)
+	if err != nil {
+		return nil, err
 	}
+	u.RawPath = rawPath
 
 	return &URL{
 		URL: u,
 	}, nil
 }
 
-func (d *DufsVFS) copyOrRename(dst, src string, isRenaming bool) error {
-	httpMethod := "COPY"
+// Overwrite is the Overwrite header sent with a copy or a rename
+type Overwrite int
+
+const (
+	// OverwriteDefault sends no header and lets the server decide
+	OverwriteDefault Overwrite = iota
+	// OverwriteFail fails with fs.ErrExist if the destination exists
+	OverwriteFail
+	// OverwriteReplace replaces the destination
+	OverwriteReplace
+)
+
+type CopyOpts struct {
+	Overwrite Overwrite
+}
+
+type RenameOpts struct {
+	Overwrite Overwrite
+}
+
+func (d *DufsVFS) copyOrRename(dst, src string, isRenaming bool, overwrite Overwrite) (err error) {
+	op, httpMethod := "Copy", "COPY"
 	if isRenaming {
-		httpMethod = "MOVE"
+		op, httpMethod = "Rename", "MOVE"
+	}
+
+	ctx, span := d.startSpan(context.Background(), op, src)
+	defer func() {
+		span.End(err)
+	}()
+
+	if isRenaming {
+		err = d.checkCapability("rename", src, canDelete)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = d.checkCapability(strings.ToLower(httpMethod), dst, canUpload)
+	if err != nil {
+		return err
 	}
 
 	srcHref, err := d.appendToRoot(src)
@@ -115,13 +320,19 @@ func (d *DufsVFS) copyOrRename(dst, src string, isRenaming bool) error {
 		return err
 	}
 
-	req, err := http.NewRequest(httpMethod, srcHref.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, httpMethod, srcHref.String(), nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Add("Destination", dstHref.String())
+	switch overwrite {
+	case OverwriteFail:
+		req.Header.Set("Overwrite", "F")
+	case OverwriteReplace:
+		req.Header.Set("Overwrite", "T")
+	}
 
-	resp, err := d.GetHttpClient().Do(req)
+	resp, err := d.Do(req)
 	if err != nil {
 		return err
 	}
@@ -130,27 +341,37 @@ func (d *DufsVFS) copyOrRename(dst, src string, isRenaming bool) error {
 	}()
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		if resp.StatusCode == http.StatusNotFound {
-			return fs.ErrNotExist
+		if resp.StatusCode == http.StatusPreconditionFailed {
+			return newStatusError(resp, fs.ErrExist)
 		}
-		return errors.New(resp.Status)
+		return statusError(resp)
 	}
 
 	return nil
 }
 
-func (d *DufsVFS) Mkdir(name string, _ fs.FileMode) error {
+func (d *DufsVFS) Mkdir(name string, _ fs.FileMode) (err error) {
+	ctx, span := d.startSpan(context.Background(), "Mkdir", name)
+	defer func() {
+		span.End(err)
+	}()
+
+	err = d.checkCapability("mkdir", name, canUpload)
+	if err != nil {
+		return err
+	}
+
 	dir, err := d.appendToRoot(name)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("MKCOL", dir.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "MKCOL", dir.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := d.GetHttpClient().Do(req)
+	resp, err := d.Do(req)
 	if err != nil {
 		return err
 	}
@@ -160,26 +381,59 @@ func (d *DufsVFS) Mkdir(name string, _ fs.FileMode) error {
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
 		if resp.StatusCode == http.StatusMethodNotAllowed {
-			return fs.ErrExist
+			return newStatusError(resp, fs.ErrExist)
+		}
+		return statusError(resp)
+	}
+
+	return nil
+}
+
+// MkdirAll
+// Creates name and its missing parents with a Mkdir each, the existing ones are skipped
+func (d *DufsVFS) MkdirAll(name string, perm fs.FileMode) error {
+	normalized, err := normalizeName(name)
+	if err != nil {
+		return err
+	}
+
+	dir := ""
+	for _, segment := range strings.Split(normalized, "/") {
+		if segment == "" {
+			continue
+		}
+		dir = path.Join(dir, segment)
+		err = d.Mkdir(dir, perm)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
 		}
-		return errors.New(resp.Status)
 	}
 
 	return nil
 }
 
-func (d *DufsVFS) Remove(name string) error {
+func (d *DufsVFS) Remove(name string) (err error) {
+	ctx, span := d.startSpan(context.Background(), "Remove", name)
+	defer func() {
+		span.End(err)
+	}()
+
+	err = d.checkCapability("remove", name, canDelete)
+	if err != nil {
+		return err
+	}
+
 	file, err := d.appendToRoot(name)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodDelete, file.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, file.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := d.GetHttpClient().Do(req)
+	resp, err := d.Do(req)
 	if err != nil {
 		return err
 	}
@@ -188,359 +442,1352 @@ func (d *DufsVFS) Remove(name string) error {
 	}()
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		if resp.StatusCode == http.StatusNotFound {
-			return fs.ErrNotExist
-		}
-		return errors.New(resp.Status)
+		return statusError(resp)
 	}
 
 	return nil
 }
 
 func (d *DufsVFS) Rename(oldname, newname string) error {
-	return d.copyOrRename(newname, oldname, true)
+	return d.copyOrRename(newname, oldname, true, OverwriteDefault)
 }
 
-func (d *DufsVFS) Copy(dst, src string) error {
-	return d.copyOrRename(dst, src, false)
+func (d *DufsVFS) RenameWithOpts(oldname, newname string, opts RenameOpts) error {
+	return d.copyOrRename(newname, oldname, true, opts.Overwrite)
 }
 
-func NewDufsFile(fs *DufsVFS, name string, Href URL) *DufsFile {
-	return &DufsFile{
-		FS:     fs,
-		Name:   name,
-		Href:   Href,
-		locker: &sync.Mutex{},
-	}
+func (d *DufsVFS) Copy(dst, src string) error {
+	return d.copyOrRename(dst, src, false, OverwriteDefault)
 }
 
-type DufsFile struct {
-	File
-	io.Seeker
-	io.ReaderAt
-	io.Writer
-	io.WriterTo
-	io.WriterAt
-
-	index       int64
-	cachedState fs.FileInfo
-
-	locker sync.Locker
-
-	FS   VFS
-	Name string
-	Href URL
+func (d *DufsVFS) CopyWithOpts(dst, src string, opts CopyOpts) error {
+	return d.copyOrRename(dst, src, false, opts.Overwrite)
 }
 
-func (d *DufsFile) determineIsDir(resp *http.Response) bool {
-	return resp.Header.Get("Content-Disposition") == "" &&
-		resp.Header.Get("Content-Type") == "application/json" &&
-		resp.Header.Get("Cache-Control") == "no-cache"
+// Chtimes
+// Always fails with errors.ErrUnsupported: dufs answers a PROPPATCH with success without storing anything,
+// and keeps the mtime of the file on disk, which only a write changes. Use a DavVFS for a server setting getlastmodified
+func (d *DufsVFS) Chtimes(name string, _ time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
 }
 
-func (d *DufsFile) jsonize() (*URL, error) {
-	href, err := d.Href.Clone()
-	if err != nil {
-		return nil, err
+// OpenParent
+// Opens the directory containing name, the root for a top level name such as "/a"
+func (d *DufsVFS) OpenParent(name string) (fs.File, error) {
+	parent := path.Dir(strings.Trim(name, "/"))
+	if parent == "." {
+		parent = ""
 	}
-	query := href.Query()
-	query.Add("json", "")
-	href.RawQuery = query.Encode()
-	return href, nil
-}
 
-func (d *DufsFile) json(method string, headers http.Header) (*http.Response, error) {
-	href, err := d.jsonize()
+	href, err := d.appendToRoot(parent)
 	if err != nil {
 		return nil, err
 	}
 
-	link := href.String()
+	return NewDufsFile(d, parent, *href), nil
+}
 
-	req, err := http.NewRequest(method, link, nil)
+// ContentType
+// Returns the Content-Type of a file without downloading it,
+// guessed from its extension if the server omits it, and application/octet-stream if the extension is unknown
+func (d *DufsVFS) ContentType(name string) (string, error) {
+	href, err := d.appendToRoot(name)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	req.Header = headers
+	file := NewDufsFile(d, name, *href)
 
-	resp, err := d.FS.GetHttpClient().Do(req)
+	resp, err := file.head(file.getContext())
 	if err != nil {
-		d.FS.GetLogger().Println("Get file", link, "with error:", err)
-		return nil, err
+		return "", err
 	}
 
-	d.FS.GetLogger().Println("Get file", link, "with status code:", resp.StatusCode)
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fs.ErrNotExist
-	} else if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return nil, fs.ErrInvalid
+	if file.determineIsDir(resp) {
+		return "", &fs.PathError{Op: "contenttype", Path: name, Err: fs.ErrInvalid}
 	}
 
-	return resp, nil
-}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
 
-func (d *DufsFile) get(headers http.Header) (*http.Response, error) {
-	return d.json(http.MethodGet, headers)
+	return contentType, nil
 }
 
-func (d *DufsFile) head() (*http.Response, error) {
-	resp, err := d.json(http.MethodHead, nil)
+// Exists
+// Tells whether name is present and a directory with a single HEAD, a missing file is not an error
+func (d *DufsVFS) Exists(name string) (exists bool, isDir bool, err error) {
+	href, err := d.appendToRoot(name)
 	if err != nil {
-		return nil, err
+		return false, false, err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-	return resp, nil
-}
 
-func (d *DufsFile) Close() error {
-	return nil
+	return NewDufsFile(d, name, *href).exists()
 }
 
-// Read
-// Inefficient with short p: use WriteTo or io.Copy instead
-func (d *DufsFile) Read(p []byte) (int, error) {
-	end := d.index + int64(len(p)) - 1
+// exists is Exists of the file, within its context
+func (d *DufsFile) exists() (exists bool, isDir bool, err error) {
+	ctx, span := d.vfs.startSpan(d.getContext(), "Exists", d.Name)
+	defer func() {
+		span.End(err)
+	}()
 
-	stat, err := d.CachedStat()
+	jsonHref, err := d.jsonize()
 	if err != nil {
-		return 0, err
+		return false, false, err
 	}
 
-	if end >= stat.Size() {
-		end = stat.Size() - 1
-	}
+	link := jsonHref.String()
 
-	if d.index >= end {
-		return 0, io.EOF
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return false, false, err
 	}
 
-	header := http.Header{}
-	header.Set("Range", fmt.Sprintf("bytes=%d-%d", d.index, end))
-
-	resp, err := d.get(header)
+	resp, err := d.vfs.Do(req)
 	if err != nil {
-		return 0, err
+		logFields(d.vfs.GetLogger(), "Head file", "method", http.MethodHead, "url", link, "error", err)
+		return false, false, d.contextErr(err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
-	if d.determineIsDir(resp) {
-		return 0, fs.ErrInvalid
+	logFields(d.vfs.GetLogger(), "Head file", "method", http.MethodHead, "url", link, "status", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotFound {
+		return false, false, nil
+	} else if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return false, false, statusError(resp)
 	}
 
-	d.index = end + 1
-
-	buf := bytes.NewBuffer(nil)
-	n, err := io.CopyN(buf, resp.Body, resp.ContentLength)
-
-	copy(p, buf.Bytes())
-
-	return int(n), err
+	return true, d.determineIsDir(resp), nil
 }
 
-func (d *DufsFile) ReadAt(p []byte, off int64) (int, error) {
-	_, err := d.Seek(off, io.SeekStart)
+// WriteFile
+// Replaces name with data in a single upload like os.WriteFile, perm is ignored as dufs does not store modes
+func (d *DufsVFS) WriteFile(name string, data []byte, _ fs.FileMode) error {
+	err := d.checkName("writefile", name)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return d.Read(p)
-}
 
-func (d *DufsFile) ReadFrom(reader io.Reader) (int64, error) {
-	href := d.Href.String()
-	contentLength := int64(0)
-	req, err := http.NewRequest(http.MethodPut, href, NewSumReader(reader, &contentLength))
+	href, err := d.appendToRoot(name)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	resp, err := d.FS.GetHttpClient().Do(req)
+	n, err := NewDufsFile(d, name, *href).ReadFromSized(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
-		d.FS.GetLogger().Println("Put file error:", err)
-		return 0, err
+		return err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	d.FS.GetLogger().Println("Put file", href, " with ReadFrom result in status code:", resp.StatusCode)
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return 0, errors.New(resp.Status)
+	if n != int64(len(data)) {
+		return &fs.PathError{Op: "write", Path: name, Err: io.ErrShortWrite}
 	}
 
-	d.cachedState = nil
-
-	return contentLength, nil
+	return nil
 }
 
-func (d *DufsFile) ReadDir(n int) ([]fs.DirEntry, error) {
-	resp, err := d.get(nil)
+// OpenFile
+// Opens name like os.OpenFile, perm is ignored as dufs does not store modes.
+// O_CREATE creates an empty file if missing, O_EXCL fails with fs.ErrExist if present,
+// O_TRUNC empties the file, and O_APPEND makes every Write append to the end of the file whatever the offset is
+func (d *DufsVFS) OpenFile(name string, flag int, _ fs.FileMode) (File, error) {
+	href, err := d.appendToRoot(name)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	if !d.determineIsDir(resp) {
-		return nil, fs.ErrInvalid
-	}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	file := NewDufsFile(d, name, *href)
+	file.readOnly = flag&(os.O_WRONLY|os.O_RDWR) == 0
+	file.writeOnly = flag&os.O_WRONLY != 0
+	file.appending = flag&os.O_APPEND != 0
 
-	var root DufsJSONIndex
-	err = json.Unmarshal(data, &root)
+	exists, isDir, err := d.Exists(name)
 	if err != nil {
 		return nil, err
 	}
 
-	var entries []fs.DirEntry
-	for _, file := range root.Paths {
-		entries = append(entries, &HttpDirEntry{
-			info: &HttpFileInfo{
-				name:  file.Name,
-				size:  file.Size,
-				mode:  fs.ModePerm,
-				mtime: time.Unix(file.MTime, 0),
-				isDir: file.PathType == PathTypeDir,
-			},
-		})
-		if n > 0 && len(entries) >= n {
-			break
+	if !exists {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		_, err = file.upload(file.getContext(), bytes.NewReader(nil), 0)
+		if err != nil {
+			return nil, err
 		}
+		return file, nil
 	}
 
-	return entries, nil
-}
-
-func (d *DufsFile) Stat() (fs.FileInfo, error) {
-	resp, err := d.head()
-	if err != nil {
-		return nil, err
+	if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
 	}
-
-	lastModified := resp.Header.Get("Last-Modified")
-	if lastModified == "" {
-		lastModified = resp.Header.Get("Date")
+	if isDir && !file.readOnly {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
 	}
 
-	var mtime time.Time
-
-	if lastModified != "" {
-		mtime, err = time.Parse(time.RFC1123, lastModified)
+	if flag&os.O_TRUNC != 0 && !file.readOnly {
+		_, err = file.upload(file.getContext(), bytes.NewReader(nil), 0)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	isDir := d.determineIsDir(resp)
-	size := int64(0)
-
-	if !isDir {
-		size = resp.ContentLength
-	}
+	return file, nil
+}
 
-	stat := &HttpFileInfo{
-		name:  d.Name,
-		size:  size,
-		mode:  fs.ModePerm,
-		mtime: mtime,
-		isDir: isDir,
+// OpenWithStat
+// Opens name with a single GET, the returned file reads from the response body until it is seeked elsewhere
+func (d *DufsVFS) OpenWithStat(name string) (fs.File, fs.FileInfo, error) {
+	href, err := d.appendToRoot(name)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	d.cachedState = stat
-
-	return stat, nil
-}
+	file := NewDufsFile(d, name, *href)
 
-func (d *DufsFile) CachedStat() (fs.FileInfo, error) {
-	d.locker.Lock()
-	defer d.locker.Unlock()
+	header := http.Header{}
+	header.Set("Accept-Encoding", "identity")
 
-	if d.cachedState != nil {
-		return d.cachedState, nil
+	resp, err := file.get(file.getContext(), header)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return d.Stat()
-}
-
-func (d *DufsFile) WriteTo(writer io.Writer) (int64, error) {
-	resp, err := d.get(http.Header{})
+	stat, err := file.cacheStat(resp)
 	if err != nil {
-		return 0, err
+		_ = resp.Body.Close()
+		return nil, nil, err
 	}
-	defer func() {
+
+	if stat.IsDir() {
 		_ = resp.Body.Close()
-	}()
+	} else {
+		file.stream = resp.Body
+	}
 
-	return io.Copy(writer, resp.Body)
+	return file, stat, nil
 }
 
-// Write
-// Inefficient with short p: use WriteTo instead
-func (d *DufsFile) Write(p []byte) (n int, err error) {
-	return d.WriteAt(p, d.index)
+func NewDufsFile(fs *DufsVFS, name string, Href URL) *DufsFile {
+	return &DufsFile{
+		vfs:  fs,
+		FS:   fs,
+		Name: name,
+		Href: Href,
+
+		cachedStateLocker: &sync.Mutex{},
+	}
+}
+
+// DufsFile
+// Read, Write and Seek are serialized on a handle, but they share its offset,
+// use ReadAt or ReadFullAt, or a Clone per goroutine, to read concurrently
+type DufsFile struct {
+	File
+	io.Seeker
+	io.ReaderAt
+	io.Writer
+	io.WriterTo
+	io.WriterAt
+
+	ctx         context.Context
+	index       int64
+	cachedState fs.FileInfo
+	cachedAt    time.Time
+	// validator is the ETag or Last-Modified of cachedState, sent as If-Range with ranged reads
+	validator string
+	// rangesUnsupported is set when the server sent Accept-Ranges: none with cachedState, or ignored a Range,
+	// the reads at an offset are then served from spill
+	rangesUnsupported bool
+	stream            io.ReadCloser
+
+	// spill is a copy of the whole file as of spillStat, in memory or in spillFile
+	spill          io.ReaderAt
+	spillFile      *os.File
+	spillStat      fs.FileInfo
+	spillValidator string
+	spillLocker    sync.Mutex
+
+	indexLocker       sync.Mutex
+	cachedStateLocker sync.Locker
+
+	// content is the whole file as of contentETag, kept when it is smaller than ReadCacheSize
+	content       []byte
+	contentETag   string
+	contentLocker sync.Mutex
+
+	// entries are the rest of an HTML listing returned n at a time by ReadDir, once listed,
+	// pullEntry decodes the next entry of a JSON index, stopListing closes it
+	entries     []fs.DirEntry
+	listed      bool
+	pullEntry   func() (fs.DirEntry, error, bool)
+	stopListing func()
+
+	// readOnly, writeOnly and appending are the access mode of a file opened with OpenFile
+	readOnly  bool
+	writeOnly bool
+	appending bool
+
+	vfs  *DufsVFS
+	FS   VFS
+	Name string
+	Href URL
+
+	// ContentType is sent with the uploads of the file, if empty it is the type of the extension of Name,
+	// or else the type sniffed from the first bytes uploaded
+	ContentType string
+}
+
+func (d *DufsFile) determineIsDir(resp *http.Response) bool {
+	return resp.Header.Get("Content-Disposition") == "" &&
+		resp.Header.Get("Content-Type") == "application/json" &&
+		resp.Header.Get("Cache-Control") == "no-cache"
+}
+
+// determineIsHTMLListing reports whether the server answered a directory request with an HTML page instead of JSON
+func (d *DufsFile) determineIsHTMLListing(resp *http.Response) bool {
+	return resp.Header.Get("Content-Disposition") == "" &&
+		strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html")
+}
+
+func (d *DufsFile) jsonize() (*URL, error) {
+	href, err := d.Href.Clone()
+	if err != nil {
+		return nil, err
+	}
+	href.addQuery("json", "")
+	return href, nil
+}
+
+func (d *DufsFile) jsonContext(ctx context.Context, method string, headers http.Header) (*http.Response, error) {
+	href, err := d.jsonize()
+	if err != nil {
+		return nil, err
+	}
+
+	link := href.String()
+
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = headers
+
+	resp, err := d.FS.Do(req)
+	if err != nil {
+		logFields(d.FS.GetLogger(), "Get file", "method", method, "url", link, "error", err)
+		return nil, err
+	}
+
+	logFields(d.FS.GetLogger(), "Get file", "method", method, "url", link, "status", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified && headers.Get("If-None-Match") != "" {
+		return resp, nil
+	} else if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		_ = resp.Body.Close()
+		statusErr := statusError(resp)
+		if statusErr.Err == nil {
+			statusErr.Err = fs.ErrInvalid
+		}
+		return nil, statusErr
+	}
+
+	return resp, nil
+}
+
+func (d *DufsFile) get(ctx context.Context, headers http.Header) (*http.Response, error) {
+	if d.vfs.EnableGzip {
+		headers = acceptGzip(headers)
+	}
+
+	resp, err := d.jsonContext(ctx, http.MethodGet, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = decompress(resp)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (d *DufsFile) head(ctx context.Context) (*http.Response, error) {
+	resp, err := d.jsonContext(ctx, http.MethodHead, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	return resp, nil
+}
+
+// checkAccess fails op with fs.ErrPermission if the file was opened without the access it needs
+func (d *DufsFile) checkAccess(op string, writing bool) error {
+	if (writing && d.readOnly) || (!writing && d.writeOnly) {
+		return &fs.PathError{Op: op, Path: d.Name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (d *DufsFile) Close() error {
+	d.indexLocker.Lock()
+	if d.stopListing != nil {
+		d.stopListing()
+	}
+	d.indexLocker.Unlock()
+	d.dropSpill()
+	return d.closeStream()
+}
+
+func (d *DufsFile) closeStream() error {
+	if d.stream == nil {
+		return nil
+	}
+	err := d.stream.Close()
+	d.stream = nil
+	return err
+}
+
+// Read
+// Inefficient with short p: use WriteTo or io.Copy instead
+func (d *DufsFile) Read(p []byte) (n int, err error) {
+	err = d.checkAccess("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, span := d.vfs.startSpan(d.getContext(), "Read", d.Name)
+	defer func() {
+		span.End(readSpanError(err))
+	}()
+
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+	return d.read(ctx, p)
+}
+
+func (d *DufsFile) read(ctx context.Context, p []byte) (int, error) {
+	if d.stream != nil {
+		n, err := d.stream.Read(p)
+		atomic.AddInt64(&d.index, int64(n))
+		if err == io.EOF {
+			_ = d.closeStream()
+		}
+		return n, err
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	stat, err := d.cachedStat(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	p, ok := clampToSize(p, d.index, stat.Size())
+	if !ok {
+		return 0, io.EOF
+	}
+
+	n, err := d.readRange(ctx, p, d.index)
+	atomic.AddInt64(&d.index, int64(n))
+	if err == nil && n == 0 {
+		return 0, io.EOF
+	}
+
+	return n, err
+}
+
+// ReadAt
+// Reads with a Range request of its own, the offset of the file is neither used nor moved, so it is safe for concurrent use
+func (d *DufsFile) ReadAt(p []byte, off int64) (n int, err error) {
+	err = d.checkAccess("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, span := d.vfs.startSpan(d.getContext(), "ReadAt", d.Name)
+	defer func() {
+		span.End(readSpanError(err))
+	}()
+
+	if off < 0 {
+		return 0, errors.New("dufs: negative offset")
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	stat, err := d.cachedStat(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	want, ok := clampToSize(p, off, stat.Size())
+	if !ok {
+		return 0, io.EOF
+	}
+
+	n, err = d.readRange(ctx, want, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+// ReadFullAt
+// Reads exactly len(p) bytes at off, returns io.ErrUnexpectedEOF if the file ends before p is filled
+func (d *DufsFile) ReadFullAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	err := d.checkAccess("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	stat, err := d.CachedStat()
+	if err != nil {
+		return 0, err
+	}
+
+	want, ok := clampToSize(p, off, stat.Size())
+	if !ok {
+		return 0, io.EOF
+	}
+
+	read := 0
+	for read < len(want) {
+		n, err := d.readRange(d.getContext(), want[read:], off+int64(read))
+		read += n
+		if err != nil {
+			return read, err
+		} else if n == 0 {
+			break
+		}
+	}
+
+	if read == 0 {
+		return 0, io.EOF
+	} else if read < len(p) {
+		return read, io.ErrUnexpectedEOF
+	}
+
+	return read, nil
+}
+
+// clampToSize cuts p to the bytes of a file of size left at off, ok is false if there are none.
+// A negative size is unknown, such as for a HEAD answered without Content-Length, p is then left as is
+func clampToSize(p []byte, off, size int64) (_ []byte, ok bool) {
+	if size < 0 {
+		return p, true
+	}
+	if off >= size {
+		return nil, false
+	}
+	return p[:min(int64(len(p)), size-off)], true
+}
+
+// readRange reads at most len(p) bytes at off with a single Range request, without moving the file index,
+// reading the body until EOF as a chunked response has no Content-Length. A 416 reads nothing
+func (d *DufsFile) readRange(ctx context.Context, p []byte, off int64) (int, error) {
+	if d.vfs.ReadCacheSize > 0 {
+		stat, err := d.cachedStat(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if !stat.IsDir() && stat.Size() <= d.vfs.ReadCacheSize {
+			return d.readCached(ctx, p, off)
+		}
+	}
+
+	if !d.acceptsRanges() {
+		return d.readSpilled(ctx, p, off)
+	}
+
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	d.setIfRange(header)
+
+	resp, err := d.get(ctx, header)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusRequestedRangeNotSatisfiable {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if d.determineIsDir(resp) {
+		return 0, fs.ErrInvalid
+	}
+
+	err = d.checkUnchanged(resp)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusPartialContent && off > 0 {
+		logFields(d.FS.GetLogger(), "Range ignored", "url", d.Href.String(), "status", resp.StatusCode)
+		d.cachedStateLocker.Lock()
+		d.rangesUnsupported = true
+		d.cachedStateLocker.Unlock()
+		return d.readSpilled(ctx, p, off)
+	}
+
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+
+	return n, d.contextErr(err)
+}
+
+// validatorOf returns the strong ETag of resp, or its Last-Modified, as If-Range does not accept weak ETags
+func validatorOf(resp *http.Response) string {
+	etag := resp.Header.Get("ETag")
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+func (d *DufsFile) setIfRange(header http.Header) {
+	d.cachedStateLocker.Lock()
+	validator := d.validator
+	d.cachedStateLocker.Unlock()
+
+	if validator != "" {
+		header.Set("If-Range", validator)
+	}
+}
+
+// checkUnchanged fails with ErrFileChanged when the server answered a ranged read with the whole file because If-Range did not match
+func (d *DufsFile) checkUnchanged(resp *http.Response) error {
+	d.cachedStateLocker.Lock()
+	validator := d.validator
+	d.cachedStateLocker.Unlock()
+
+	if resp.StatusCode != http.StatusOK || validator == "" || validatorOf(resp) == validator {
+		return nil
+	}
+	logFields(d.FS.GetLogger(), "File changed", "url", d.Href.String(), "from", validator, "to", validatorOf(resp))
+	d.forgetStat()
+	return ErrFileChanged
+}
+
+// ReadFrom
+// Replaces the whole file with the content of reader whatever the offset is, which is rewound to 0 on success,
+// so a Read after io.Copy(file, src) reads back what was uploaded.
+// Note io.Copy only calls ReadFrom if src is not an io.WriterTo, otherwise src is written at the offset with Write
+func (d *DufsFile) ReadFrom(reader io.Reader) (int64, error) {
+	return d.put(d.getContext(), reader, -1)
+}
+
+// ReadFromSized
+// Same as ReadFrom, but sends size as Content-Length, reader must provide exactly size bytes
+func (d *DufsFile) ReadFromSized(reader io.Reader, size int64) (int64, error) {
+	if size < 0 {
+		return 0, errors.New("dufs: negative size")
+	}
+	return d.put(d.getContext(), reader, size)
+}
+
+func (d *DufsFile) put(ctx context.Context, reader io.Reader, size int64) (n int64, err error) {
+	err = d.checkAccess("write", true)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, span := d.vfs.startSpan(ctx, "ReadFrom", d.Name)
+	defer func() {
+		span.End(err)
+	}()
+
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+
+	if d.vfs.UploadProgress != nil {
+		reader = newProgressReader(reader, size, d.vfs.UploadProgress)
+	}
+
+	n, err = d.upload(ctx, reader, size)
+	if err != nil {
+		return n, err
+	}
+
+	_ = d.closeStream()
+	atomic.StoreInt64(&d.index, 0)
+
+	return n, nil
+}
+
+func (d *DufsFile) upload(ctx context.Context, reader io.Reader, size int64) (int64, error) {
+	err := d.vfs.checkCapability("write", d.Name, canUpload)
+	if err != nil {
+		return 0, err
+	}
+
+	contentType, reader, err := sniffContentType(d.ContentType, d.Name, reader)
+	if err != nil {
+		return 0, err
+	}
+
+	threshold := d.vfs.SmallFileThreshold
+	if threshold <= 0 || (size >= 0 && size < threshold) {
+		return d.putOnce(ctx, reader, size, contentType)
+	}
+
+	if size < 0 {
+		head := make([]byte, threshold)
+		n, err := io.ReadFull(reader, head)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return d.putOnce(ctx, bytes.NewReader(head[:n]), int64(n), contentType)
+		} else if err != nil {
+			return 0, err
+		}
+		reader = io.MultiReader(bytes.NewReader(head), reader)
+	}
+
+	return d.putInParts(ctx, reader, size, contentType)
+}
+
+func (d *DufsFile) putOnce(ctx context.Context, reader io.Reader, size int64, contentType string) (int64, error) {
+	href := d.Href.String()
+	contentLength := int64(0)
+	newBody := func() io.Reader {
+		contentLength = 0
+		if size >= 0 {
+			return NewSumReader(io.LimitReader(reader, size+1), &contentLength)
+		}
+		return NewSumReader(reader, &contentLength)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, href, newBody())
+	if err != nil {
+		return 0, err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rewindOnRetry(req, reader, newBody)
+
+	resp, err := d.FS.Do(req)
+	if err != nil {
+		logFields(d.FS.GetLogger(), "Put file", "method", http.MethodPut, "url", href, "error", err)
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	logFields(d.FS.GetLogger(), "Put file", "method", http.MethodPut, "url", href, "status", resp.StatusCode, "bytes", contentLength)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return 0, statusError(resp)
+	}
+
+	d.forgetStat()
+
+	if size >= 0 && contentLength != size {
+		return contentLength, fmt.Errorf("dufs: expected %d bytes, read %d", size, contentLength)
+	}
+
+	return contentLength, nil
+}
+
+// ReadDir
+// Lists the directory once, then returns its entries n at a time like os.File.ReadDir,
+// use ReadDirStream for a directory too large to be held in memory
+func (d *DufsFile) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+
+	if !d.listed {
+		err = d.list()
+		if err != nil {
+			return nil, err
+		}
+		d.listed = true
+	}
+
+	return readDirPage(&d.entries, n, d.nextEntry)
+}
+
+// list requests the listing of the directory, an HTML listing is read at once into entries,
+// a JSON index is left open for nextEntry to decode on demand
+func (d *DufsFile) list() (err error) {
+	ctx, span := d.vfs.startSpan(d.getContext(), "ReadDir", d.Name)
+	defer func() {
+		span.End(err)
+	}()
+
+	resp, err := d.get(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if !d.determineIsDir(resp) {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		if !d.determineIsHTMLListing(resp) {
+			return fs.ErrInvalid
+		} else if !d.vfs.HTMLListing {
+			return ErrNoJSONListing
+		}
+		d.entries, err = d.readHTMLDir(resp, -1)
+		return err
+	}
+
+	// decoded as it arrives, so a huge or chunked listing is never held in memory as a whole
+	d.pullEntry, d.stopListing = iter.Pull2(func(yield func(fs.DirEntry, error) bool) {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		err := decodePaths(json.NewDecoder(resp.Body), func(file DufsJSONFile) bool {
+			return yield(d.dirEntry(file), nil)
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	})
+
+	return nil
+}
+
+// nextEntry decodes the next entry of a JSON index left open by list, ok is false once it is over
+func (d *DufsFile) nextEntry() (entry fs.DirEntry, err error, ok bool) {
+	if d.pullEntry == nil {
+		return nil, nil, false
+	}
+	return d.pullEntry()
+}
+
+func (d *DufsFile) dirEntry(file DufsJSONFile) fs.DirEntry {
+	mtime := d.vfs.MTimeUnit.Time(file.MTime).UTC()
+	if d.vfs.StrictFS {
+		// to the second like the Last-Modified of a Stat, so both describe the file the same way
+		mtime = mtime.Truncate(time.Second)
+	}
+
+	return &HttpDirEntry{
+		info: &HttpFileInfo{
+			name:  d.vfs.GetPathEncoder().Decode(file.Name),
+			size:  file.Size,
+			mode:  fs.ModePerm,
+			mtime: mtime,
+			isDir: file.PathType == PathTypeDir,
+		},
+	}
+}
+
+func (d *DufsFile) readHTMLDir(resp *http.Response, n int) ([]fs.DirEntry, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fs.DirEntry
+	for _, info := range parseHTMLListing(data, resp.Request.URL) {
+		entries = append(entries, &HttpDirEntry{
+			info: info,
+		})
+		if n > 0 && len(entries) >= n {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+func (d *DufsFile) Stat() (stat fs.FileInfo, err error) {
+	ctx, span := d.vfs.startSpan(d.getContext(), "Stat", d.Name)
+	defer func() {
+		span.End(err)
+	}()
+
+	return d.stat(ctx)
+}
+
+func (d *DufsFile) stat(ctx context.Context) (fs.FileInfo, error) {
+	resp, err := d.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.cacheStat(resp)
+}
+
+// cacheStat sets the cached state from a response to a HEAD or GET of the whole file
+func (d *DufsFile) cacheStat(resp *http.Response) (fs.FileInfo, error) {
+	d.cachedStateLocker.Lock()
+	defer d.cachedStateLocker.Unlock()
+	return d.cacheStatLocked(resp)
+}
+
+// cacheStatLocked is cacheStat with cachedStateLocker held
+func (d *DufsFile) cacheStatLocked(resp *http.Response) (fs.FileInfo, error) {
+	stat, err := d.fileInfo(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	d.cachedState = stat
+	d.cachedAt = time.Now()
+	d.validator = validatorOf(resp)
+	// a missing Accept-Ranges is not taken as none: many servers honor Range without advertising it,
+	// and a Range answered with the whole file is detected by readRange, which falls back to the copy then
+	d.rangesUnsupported = !stat.IsDir() && resp.Header.Get("Accept-Ranges") == "none"
+
+	return stat, nil
+}
+
+func (d *DufsFile) fileInfo(resp *http.Response) (*HttpFileInfo, error) {
+	lastModified := resp.Header.Get("Last-Modified")
+	if lastModified == "" {
+		lastModified = resp.Header.Get("Date")
+	}
+
+	mtime := parseHTTPTime(lastModified)
+
+	isDir := d.determineIsDir(resp)
+	size := int64(0)
+
+	if !isDir {
+		size = resp.ContentLength
+	}
+
+	return &HttpFileInfo{
+		name:  baseName(d.Name),
+		size:  size,
+		mode:  fs.ModePerm,
+		mtime: mtime,
+		isDir: isDir,
+
+		header: resp.Header.Clone(),
+	}, nil
+}
+
+// httpTimeLayouts are tried by parseHTTPTime after http.ParseTime, for servers sending a numeric or non-GMT zone
+var httpTimeLayouts = []string{time.RFC1123Z, time.RFC1123, time.RFC3339}
+
+// parseHTTPTime parses a Last-Modified or Date header in UTC, the zero time if it is empty or cannot be parsed
+func parseHTTPTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return t.UTC()
+	}
+	for _, layout := range httpTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+
+	return time.Time{}
+}
+
+func (d *DufsFile) CachedStat() (fs.FileInfo, error) {
+	return d.cachedStat(d.getContext())
+}
+
+// cachedStat is CachedStat sending the HEAD, if any, with ctx
+func (d *DufsFile) cachedStat(ctx context.Context) (_ fs.FileInfo, err error) {
+	d.cachedStateLocker.Lock()
+	defer d.cachedStateLocker.Unlock()
+
+	if d.cachedState != nil && (d.vfs.StatTTL <= 0 || time.Since(d.cachedAt) < d.vfs.StatTTL) {
+		return d.cachedState, nil
+	}
+
+	ctx, span := d.vfs.startSpan(ctx, "Stat", d.Name)
+	defer func() {
+		span.End(err)
+	}()
+
+	resp, err := d.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.cacheStatLocked(resp)
+}
+
+// forgetStat drops the cached state, such as after a write, so the next CachedStat sends a HEAD
+func (d *DufsFile) forgetStat() {
+	d.cachedStateLocker.Lock()
+	defer d.cachedStateLocker.Unlock()
+
+	d.cachedState = nil
+	d.cachedAt = time.Time{}
+	d.validator = ""
+}
+
+func (d *DufsFile) WriteTo(writer io.Writer) (int64, error) {
+	err := d.checkAccess("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	if d.stream != nil {
+		d.indexLocker.Lock()
+		defer d.indexLocker.Unlock()
+		defer func() {
+			_ = d.closeStream()
+		}()
+		n, err := io.Copy(writer, d.stream)
+		atomic.AddInt64(&d.index, n)
+		return n, err
+	}
+
+	if d.vfs.TarDirectories {
+		stat, err := d.CachedStat()
+		if err != nil {
+			return 0, err
+		}
+		if stat.IsDir() {
+			written := int64(0)
+			err = d.vfs.TarDir(NewSumWriter(writer, &written), d.Name)
+			return written, err
+		}
+	}
+
+	resp, err := d.get(d.getContext(), http.Header{})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	n, err := io.Copy(writer, resp.Body)
+	return n, d.contextErr(err)
+}
+
+// ReadLines
+// Downloads the file and splits it by "\n", a "\r" before it is dropped too.
+// The empty line after a terminal newline is dropped unless KeepTrailingEmptyLine is set
+func (d *DufsFile) ReadLines() ([]string, error) {
+	buf := bytes.NewBuffer(nil)
+	_, err := d.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	if !d.vfs.KeepTrailingEmptyLine && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines, nil
+}
+
+// Write
+// Inefficient with short p: use WriteTo instead
+func (d *DufsFile) Write(p []byte) (n int, err error) {
+	err = d.checkAccess("write", true)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, span := d.vfs.startSpan(d.getContext(), "Write", d.Name)
+	defer func() {
+		span.End(err)
+	}()
+
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+
+	if d.appending {
+		return d.writeAt(ctx, p, -1)
+	}
+	return d.writeAt(ctx, p, d.index)
 }
 
 func (d *DufsFile) WriteAt(p []byte, off int64) (n int, err error) {
-	href := d.Href.String()
-	req, err := http.NewRequest(http.MethodPatch, href, bytes.NewReader(p))
+	err = d.checkAccess("write", true)
+	if err != nil {
+		return 0, err
+	}
+	if d.appending || off < 0 {
+		return 0, &fs.PathError{Op: "writeat", Path: d.Name, Err: fs.ErrInvalid}
+	}
+
+	ctx, span := d.vfs.startSpan(d.getContext(), "WriteAt", d.Name)
+	defer func() {
+		span.End(err)
+	}()
+
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+	return d.writeAt(ctx, p, off)
+}
+
+// writeAt writes p at off, or appends it to the end of the file if off is negative
+func (d *DufsFile) writeAt(ctx context.Context, p []byte, off int64) (n int, err error) {
+	err = d.patch(ctx, p, off)
 	if err != nil {
 		return 0, err
 	}
 
+	if off < 0 {
+		atomic.AddInt64(&d.index, int64(len(p)))
+	} else {
+		atomic.StoreInt64(&d.index, off+int64(len(p)))
+	}
+
+	if d.vfs.UploadProgress != nil {
+		d.vfs.UploadProgress(int64(len(p)), int64(len(p)))
+	}
+
+	return len(p), nil
+}
+
+func (d *DufsFile) patch(ctx context.Context, p []byte, off int64) error {
+	err := d.vfs.checkCapability("write", d.Name, canUpload)
+	if err != nil {
+		return err
+	}
+
+	href := d.Href.String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, href, bytes.NewReader(p))
+	if err != nil {
+		return err
+	}
+
+	var head []byte
+	if off == 0 {
+		head = p
+	}
+	if contentType := contentTypeOf(d.ContentType, d.Name, head); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
 	end := off + int64(len(p)) - 1
-	req.Header.Add("x-update-range", fmt.Sprintf("bytes=%d-%d", d.index, end))
+	if off < 0 {
+		// an append sent twice would duplicate p, so it is not retried
+		req.Header.Add("x-update-range", "append")
+	} else {
+		req.Header.Add("x-update-range", fmt.Sprintf("bytes=%d-%d", off, end))
+		if d.vfs.RetryPolicy != nil && d.vfs.RetryPolicy.RetryWrites {
+			req.Header["X-Idempotency-Key"] = nil
+		}
+	}
 
-	resp, err := d.FS.GetHttpClient().Do(req)
+	resp, err := d.FS.Do(req)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
-	d.FS.GetLogger().Println("Patch file", href, " with WriteAt result in status code:", resp.StatusCode)
+	logFields(d.FS.GetLogger(), "Patch file", "method", http.MethodPatch, "url", href, "status", resp.StatusCode, "bytes", len(p))
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return 0, errors.New(resp.Status)
+		return statusError(resp)
 	}
 
-	d.index = end + 1
+	d.forgetStat()
 
-	d.cachedState = nil
+	if d.vfs.VerifyWrites {
+		stat, err := d.Stat()
+		if err != nil {
+			return err
+		}
+		if off >= 0 && stat.Size() <= end {
+			logFields(d.FS.GetLogger(), "Patch file truncated", "url", href, "size", stat.Size(), "expected", end+1)
+			return io.ErrShortWrite
+		}
+	}
 
-	return len(p), nil
+	return nil
+}
+
+// Truncate
+// Changes the size of the file, the offset is left as is.
+// Shrinking re-uploads the kept prefix, which is held in memory, growing appends zeros with a PATCH
+func (d *DufsFile) Truncate(size int64) error {
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: d.Name, Err: fs.ErrInvalid}
+	}
+
+	err := d.checkAccess("truncate", true)
+	if err != nil {
+		return err
+	}
+
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+
+	stat, err := d.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return &fs.PathError{Op: "truncate", Path: d.Name, Err: fs.ErrInvalid}
+	}
+
+	_ = d.closeStream()
+
+	switch {
+	case size > stat.Size():
+		err = d.patch(d.getContext(), make([]byte, size-stat.Size()), stat.Size())
+	case size == 0:
+		_, err = d.upload(d.getContext(), bytes.NewReader(nil), 0)
+	case size < stat.Size():
+		prefix := make([]byte, size)
+		_, err = d.ReadFullAt(prefix, 0)
+		if err != nil {
+			return err
+		}
+		_, err = d.upload(d.getContext(), bytes.NewReader(prefix), size)
+	}
+
+	d.forgetStat()
+
+	return err
 }
 
 func (d *DufsFile) Seek(offset int64, whence int) (int64, error) {
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+	return d.seek(offset, whence)
+}
+
+func (d *DufsFile) seek(offset int64, whence int) (int64, error) {
 	stat, err := d.CachedStat()
 	if err != nil {
 		return 0, err
 	}
 
+	index := d.index
+
 	switch whence {
 	case io.SeekStart:
-		d.index = offset
+		index = offset
 	case io.SeekCurrent:
-		d.index += offset
+		index += offset
 	case io.SeekEnd:
-		d.index = stat.Size() + offset
+		index = stat.Size() + offset
 	}
 
-	if d.index < 0 {
+	if index < 0 {
 		return 0, errors.New("dufs: negative offset")
-	} else if d.index > stat.Size() {
+	} else if index > stat.Size() {
 		return 0, errors.New("dufs: offset out of range")
 	}
 
-	return d.index, nil
+	if d.index != index {
+		_ = d.closeStream()
+		atomic.StoreInt64(&d.index, index)
+	}
+
+	return index, nil
+}
+
+// Tell
+// Returns the current offset, same as Seek(0, io.SeekCurrent) without a Stat and without waiting for a pending Read
+func (d *DufsFile) Tell() int64 {
+	return atomic.LoadInt64(&d.index)
+}
+
+// WithContext
+// Returns a Clone whose requests are bound to ctx, cancelling ctx aborts them with ctx.Err()
+func (d *DufsFile) WithContext(ctx context.Context) *DufsFile {
+	clone := d.Clone()
+	clone.ctx = ctx
+	return clone
+}
+
+func (d *DufsFile) getContext() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// contextErr reports a failure caused by the cancellation of the bound context as ctx.Err()
+func (d *DufsFile) contextErr(err error) error {
+	if err != nil && d.ctx != nil && d.ctx.Err() != nil {
+		return d.ctx.Err()
+	}
+	return err
+}
+
+// Clone
+// Returns a new handle of the same file, with its own offset starting at the current one
+func (d *DufsFile) Clone() *DufsFile {
+	clone := NewDufsFile(d.vfs, d.Name, d.Href)
+	clone.ctx = d.ctx
+	clone.index = d.Tell()
+	clone.readOnly = d.readOnly
+	clone.writeOnly = d.writeOnly
+	clone.appending = d.appending
+	clone.ContentType = d.ContentType
+
+	d.cachedStateLocker.Lock()
+	clone.cachedState = d.cachedState
+	clone.cachedAt = d.cachedAt
+	clone.validator = d.validator
+	clone.rangesUnsupported = d.rangesUnsupported
+	d.cachedStateLocker.Unlock()
+
+	return clone
+}
+
+// OpenChild
+// Opens name relative to this directory, name must be a valid fs path, so it can not escape with ".."
+func (d *DufsFile) OpenChild(name string) (*DufsFile, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	stat, err := d.CachedStat()
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: d.Name, Err: fs.ErrInvalid}
+	}
+
+	href, err := d.Href.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	rawPath := strings.TrimSuffix(href.EscapedPath(), "/") + "/" + d.vfs.GetPathEncoder().Encode(name)
+	href.Path, err = url.PathUnescape(rawPath)
+	if err != nil {
+		return nil, err
+	}
+	href.RawPath = rawPath
+
+	child := NewDufsFile(d.vfs, path.Join(strings.TrimPrefix(d.Name, "/"), name), *href)
+	child.ctx = d.ctx
+
+	return child, nil
 }
 
 func (d *DufsFile) String() string {
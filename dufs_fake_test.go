@@ -0,0 +1,420 @@
+package vfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDufs is an in-memory server speaking the subset of the dufs protocol used by DufsVFS
+type fakeDufs struct {
+	*httptest.Server
+
+	Delay    time.Duration
+	Requests atomic.Int64
+
+	// DenyUpload, DenyDelete, DenySearch and DenyArchive turn off the flags advertised in listings, requests are still served
+	DenyUpload  bool
+	DenyDelete  bool
+	DenySearch  bool
+	DenyArchive bool
+
+	// NoHash ignores the ?hash query like dufs before 0.38, sending the file instead
+	NoHash bool
+
+	// User is reported by the listings as the authenticated user of a server requiring authentication
+	User string
+
+	// WebDAV makes MKCOL fail with 409 Conflict for a missing parent like a plain WebDAV server, dufs creates the parents
+	WebDAV bool
+
+	// root, if not empty, is a folder the files are loaded from and every change is mirrored to, like the one served by dufs
+	root string
+
+	locker sync.Mutex
+	files  map[string][]byte
+	dirs   map[string]bool
+	mtimes map[string]time.Time
+	// contentTypes are the Content-Type of the last PUT of each file, served back with it
+	contentTypes map[string]string
+}
+
+func newFakeDufs(t *testing.T) *fakeDufs {
+	d := &fakeDufs{
+		files:  map[string][]byte{},
+		dirs:   map[string]bool{"": true},
+		mtimes: map[string]time.Time{},
+
+		contentTypes: map[string]string{},
+	}
+	d.Server = httptest.NewServer(http.HandlerFunc(d.serveHTTP))
+	t.Cleanup(d.Close)
+	return d
+}
+
+// newFakeDufsAt is newFakeDufs serving the files under root, and writing its changes back to root
+func newFakeDufsAt(t *testing.T, root string) *fakeDufs {
+	d := newFakeDufs(t)
+
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, file)
+		if err != nil || name == "." {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if entry.IsDir() {
+			d.mkdir(name)
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		d.put(name, data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.root = root
+	return d
+}
+
+// mirror applies change to the file or folder name under root, if any, a failure is only logged as the store is the source of truth
+func (d *fakeDufs) mirror(name string, change func(file string) error) {
+	if d.root == "" {
+		return
+	}
+	err := change(filepath.Join(d.root, filepath.FromSlash(name)))
+	if err != nil {
+		log.Println("fake dufs:", err)
+	}
+}
+
+func parentOf(name string) string {
+	parent := path.Dir(name)
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
+
+func (d *fakeDufs) mkdir(name string) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	d.mkdirLocked(strings.Trim(name, "/"))
+}
+
+func (d *fakeDufs) mkdirLocked(name string) {
+	d.mirror(name, func(dir string) error {
+		return os.MkdirAll(dir, 0755)
+	})
+	for name != "" {
+		d.dirs[name] = true
+		if _, ok := d.mtimes[name]; !ok {
+			d.mtimes[name] = time.Now()
+		}
+		name = parentOf(name)
+	}
+}
+
+func (d *fakeDufs) put(name string, data []byte) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	d.putLocked(strings.Trim(name, "/"), data)
+}
+
+func (d *fakeDufs) putLocked(name string, data []byte) {
+	d.mkdirLocked(parentOf(name))
+	d.files[name] = data
+	d.mtimes[name] = time.Now()
+	d.mirror(name, func(file string) error {
+		return os.WriteFile(file, data, 0644)
+	})
+}
+
+func (d *fakeDufs) get(name string) ([]byte, bool) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	data, ok := d.files[strings.Trim(name, "/")]
+	return data, ok
+}
+
+func (d *fakeDufs) index(dir string) DufsJSONIndex {
+	index := DufsJSONIndex{
+		Href:         "/" + dir,
+		Kind:         "Index",
+		UriPrefix:    "/",
+		AllowUpload:  !d.DenyUpload,
+		AllowDelete:  !d.DenyDelete,
+		AllowSearch:  !d.DenySearch,
+		AllowArchive: !d.DenyArchive,
+		DirExists:    true,
+		Auth:         d.User != "",
+		User:         d.User,
+		Paths:        []DufsJSONFile{},
+	}
+
+	for name := range d.dirs {
+		if name != "" && parentOf(name) == dir {
+			index.Paths = append(index.Paths, DufsJSONFile{
+				PathType: PathTypeDir,
+				Name:     path.Base(name),
+				MTime:    d.mtimes[name].UnixMilli(),
+			})
+		}
+	}
+	for name, data := range d.files {
+		if parentOf(name) == dir {
+			index.Paths = append(index.Paths, DufsJSONFile{
+				PathType: "File",
+				Name:     path.Base(name),
+				MTime:    d.mtimes[name].UnixMilli(),
+				Size:     int64(len(data)),
+			})
+		}
+	}
+	sort.Slice(index.Paths, func(i, j int) bool {
+		return index.Paths[i].Name < index.Paths[j].Name
+	})
+
+	return index
+}
+
+// search lists the files and directories under dir whose name contains query, like dufs, by path relative to dir
+func (d *fakeDufs) search(dir, query string) DufsJSONIndex {
+	index := d.index(dir)
+	index.Paths = []DufsJSONFile{}
+
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	query = strings.ToLower(query)
+
+	for name := range d.dirs {
+		if name != "" && strings.HasPrefix(name, prefix) && strings.Contains(strings.ToLower(path.Base(name)), query) {
+			index.Paths = append(index.Paths, DufsJSONFile{PathType: PathTypeDir, Name: name[len(prefix):]})
+		}
+	}
+	for name, data := range d.files {
+		if strings.HasPrefix(name, prefix) && strings.Contains(strings.ToLower(path.Base(name)), query) {
+			index.Paths = append(index.Paths, DufsJSONFile{PathType: "File", Name: name[len(prefix):], Size: int64(len(data))})
+		}
+	}
+	sort.Slice(index.Paths, func(i, j int) bool {
+		return index.Paths[i].Name < index.Paths[j].Name
+	})
+
+	return index
+}
+
+// removeLocked deletes name and, for a directory, everything under it
+func (d *fakeDufs) removeLocked(name string) {
+	d.mirror(name, os.RemoveAll)
+	prefix := name + "/"
+	for file := range d.files {
+		if file == name || strings.HasPrefix(file, prefix) {
+			delete(d.files, file)
+			delete(d.mtimes, file)
+		}
+	}
+	for dir := range d.dirs {
+		if dir != "" && (dir == name || strings.HasPrefix(dir, prefix)) {
+			delete(d.dirs, dir)
+			delete(d.mtimes, dir)
+		}
+	}
+}
+
+// zip writes the files under dir as a zip archive, by path relative to dir
+func (d *fakeDufs) zip(w io.Writer, dir string) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	archive := zip.NewWriter(w)
+	for name, data := range d.files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		member, err := archive.Create(name[len(prefix):])
+		if err != nil {
+			return
+		}
+		_, _ = member.Write(data)
+	}
+	_ = archive.Close()
+}
+
+func (d *fakeDufs) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	d.Requests.Add(1)
+	if d.Delay > 0 {
+		time.Sleep(d.Delay)
+	}
+
+	name := strings.Trim(r.URL.Path, "/")
+
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if d.dirs[name] {
+			if _, ok := r.URL.Query()["zip"]; ok {
+				w.Header().Set("Content-Type", "application/zip")
+				d.zip(w, name)
+				return
+			}
+			index := d.index(name)
+			if query, ok := r.URL.Query()["q"]; ok {
+				index = d.search(name, query[0])
+			}
+			data, _ := json.Marshal(index)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Last-Modified", d.mtimes[name].UTC().Format(http.TimeFormat))
+			if r.Method == http.MethodHead {
+				return
+			}
+			_, _ = w.Write(data)
+			return
+		}
+		data, ok := d.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, ok := r.URL.Query()["hash"]; ok && !d.NoHash {
+			sum := sha256.Sum256(data)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(hex.EncodeToString(sum[:])))
+			return
+		}
+		w.Header().Set("Content-Disposition", "inline; filename=\""+path.Base(name)+"\"")
+		w.Header().Set("ETag", fmt.Sprintf("\"%d-%d\"", d.mtimes[name].UnixMilli(), len(data)))
+		if contentType := d.contentTypes[name]; contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		http.ServeContent(w, r, name, d.mtimes[name], bytes.NewReader(data))
+	case http.MethodPut:
+		buf := bytes.NewBuffer(nil)
+		_, err := buf.ReadFrom(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		d.putLocked(name, buf.Bytes())
+		d.contentTypes[name] = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPatch:
+		data, ok := d.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		buf := bytes.NewBuffer(nil)
+		_, err := buf.ReadFrom(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		updateRange := r.Header.Get("x-update-range")
+		if updateRange == "append" {
+			d.putLocked(name, append(data, buf.Bytes()...))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var start, end int64
+		_, err = fmt.Sscanf(updateRange, "bytes=%d-%d", &start, &end)
+		if err != nil || start > int64(len(data)) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		patched := append([]byte{}, data[:start]...)
+		patched = append(patched, buf.Bytes()...)
+		if tail := start + int64(buf.Len()); tail < int64(len(data)) {
+			patched = append(patched, data[tail:]...)
+		}
+		d.putLocked(name, patched)
+		w.WriteHeader(http.StatusNoContent)
+	case "COPY", "MOVE":
+		destination, err := url.Parse(r.Header.Get("Destination"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		dst := strings.Trim(destination.Path, "/")
+		if !d.dirs[name] && d.files[name] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Overwrite") == "F" && (d.dirs[dst] || d.files[dst] != nil) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		prefix := name + "/"
+		if d.dirs[name] {
+			d.mkdirLocked(dst)
+		}
+		for file, data := range d.files {
+			if file == name {
+				d.putLocked(dst, append([]byte{}, data...))
+			} else if strings.HasPrefix(file, prefix) {
+				d.putLocked(dst+"/"+file[len(prefix):], append([]byte{}, data...))
+			}
+		}
+		if r.Method == "MOVE" {
+			d.removeLocked(name)
+		}
+		w.WriteHeader(http.StatusCreated)
+	case "MKCOL":
+		if d.dirs[name] || d.files[name] != nil {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if d.WebDAV && !d.dirs[parentOf(name)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d.mkdirLocked(name)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if name == "" || (!d.dirs[name] && d.files[name] == nil) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		d.removeLocked(name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
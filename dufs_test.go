@@ -0,0 +1,1543 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDufsReadFromSized(t *testing.T) {
+	var (
+		contentLength int64
+		body          []byte
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		contentLength = r.ContentLength
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("sized.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := bytes.Repeat([]byte("0123456789"), 1024)
+
+	n, err := file.(*DufsFile).ReadFromSized(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(len(data)) {
+		t.Fatalf("written size mismatch, expected %d, got %d", len(data), n)
+	}
+
+	if contentLength != int64(len(data)) {
+		t.Fatalf("Content-Length mismatch, expected %d, got %d", len(data), contentLength)
+	}
+
+	if !bytes.Equal(body, data) {
+		t.Fatal("uploaded content mismatch")
+	}
+
+	_, err = file.(*DufsFile).ReadFromSized(bytes.NewReader(data), int64(len(data))+1)
+	if err == nil {
+		t.Fatal("short reader should fail")
+	}
+
+	_, err = file.(*DufsFile).ReadFromSized(bytes.NewReader(data), int64(len(data))-1)
+	if err == nil {
+		t.Fatal("long reader should fail")
+	}
+}
+
+func TestDufsOpenWithStat(t *testing.T) {
+	server := newFakeDufs(t)
+	data := bytes.Repeat([]byte("open-with-stat"), 4096)
+	server.put("a/stat.bin", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, stat, err := dufs.OpenWithStat("a/stat.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if stat.Name() != "stat.bin" {
+		t.Fatal("file name should be stat.bin, got", stat.Name())
+	}
+
+	if stat.IsDir() {
+		t.Fatal("a/stat.bin should not be a directory")
+	}
+
+	if stat.Size() != int64(len(data)) {
+		t.Fatalf("file size mismatch, expected %d, got %d", len(data), stat.Size())
+	}
+
+	if stat.ModTime().IsZero() {
+		t.Fatal("mtime should be set")
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(content, data) {
+		t.Fatal("read data mismatch")
+	}
+
+	if n := server.Requests.Load(); n != 1 {
+		t.Fatal("OpenWithStat should issue exactly one request, got", n)
+	}
+}
+
+// newPartialServer serves data, but answers every Range request with at most limit bytes
+func newPartialServer(t *testing.T, data []byte, limit int64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "inline")
+		w.Header().Set("Accept-Ranges", "bytes")
+
+		var start, end int64
+		_, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		if err != nil {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(data)
+			}
+			return
+		}
+
+		end = min(end+1, start+limit, int64(len(data)))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(data)))
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(data[start:end])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDufsReadFullAt(t *testing.T) {
+	const recordSize = 256
+
+	data := make([]byte, recordSize*16)
+	for i := range data {
+		data[i] = byte(i / recordSize)
+	}
+
+	server := newPartialServer(t, data, 100)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("records.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := make([]byte, recordSize)
+	for _, i := range []int{0, 3, 7, 15} {
+		n, err := file.(*DufsFile).ReadFullAt(record, int64(i*recordSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != recordSize || !bytes.Equal(record, bytes.Repeat([]byte{byte(i)}, recordSize)) {
+			t.Fatal("record", i, "mismatch")
+		}
+	}
+
+	n, err := file.(*DufsFile).ReadFullAt(record, int64(len(data)-10))
+	if err != io.ErrUnexpectedEOF || n != 10 {
+		t.Fatal("reading past the end should return io.ErrUnexpectedEOF with 10 bytes, got", n, err)
+	}
+
+	n, err = file.(*DufsFile).ReadFullAt(record, int64(len(data)))
+	if err != io.EOF || n != 0 {
+		t.Fatal("reading at the end should return io.EOF, got", n, err)
+	}
+}
+
+func TestMTimeUnit(t *testing.T) {
+	expected := time.Date(2024, 10, 17, 8, 30, 15, 123456789, time.UTC)
+
+	cases := []struct {
+		unit      MTimeUnit
+		mtime     int64
+		precision time.Duration
+	}{
+		{MTimeUnitMillisecond, expected.UnixMilli(), time.Millisecond},
+		{MTimeUnitSecond, expected.Unix(), time.Second},
+		{MTimeUnitMicrosecond, expected.UnixMicro(), time.Microsecond},
+		{MTimeUnitNanosecond, expected.UnixNano(), time.Nanosecond},
+		{MTimeUnitAuto, expected.UnixMilli(), time.Millisecond},
+		{MTimeUnitAuto, expected.Unix(), time.Second},
+		{MTimeUnitAuto, expected.UnixMicro(), time.Microsecond},
+		{MTimeUnitAuto, expected.UnixNano(), time.Nanosecond},
+	}
+
+	for _, c := range cases {
+		mtime := c.unit.Time(c.mtime)
+		if !mtime.Equal(expected.Truncate(c.precision)) {
+			t.Fatalf("unit %d with %d should be %s, got %s", c.unit, c.mtime, expected.Truncate(c.precision), mtime)
+		}
+	}
+}
+
+func TestDufsTell(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("tell.txt", []byte("0123456789abcdefghij"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("tell.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	if f.Tell() != 0 {
+		t.Fatal("new file should be at 0, got", f.Tell())
+	}
+
+	buf := make([]byte, 4)
+	_, err = f.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Tell() != 4 {
+		t.Fatal("file should be at 4 after reading 4 bytes, got", f.Tell())
+	}
+
+	_, err = f.Seek(6, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Tell() != 10 {
+		t.Fatal("file should be at 10 after seeking 6 more, got", f.Tell())
+	}
+
+	_, err = f.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "abcd" || f.Tell() != 14 {
+		t.Fatal("file should read abcd and be at 14, got", string(buf), f.Tell())
+	}
+
+	_, err = f.Seek(-5, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Tell() != 15 {
+		t.Fatal("file should be at 15 after seeking from the end, got", f.Tell())
+	}
+}
+
+func TestDufsConcurrentReads(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuv")
+
+	server := newFakeDufs(t)
+	server.put("concurrent.txt", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("concurrent.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+
+	for i := 0; i < len(data); i += 4 {
+		off := int64(i)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 4)
+			_, err := f.ReadAt(buf, off)
+			if err != nil {
+				errs <- err
+			} else if !bytes.Equal(buf, data[off:off+4]) {
+				errs <- fmt.Errorf("ReadAt %d: expected %q, got %q", off, data[off:off+4], buf)
+			}
+			_ = f.Tell()
+		}()
+		go func() {
+			defer wg.Done()
+			clone := f.Clone()
+			defer func() {
+				_ = clone.Close()
+			}()
+			buf := make([]byte, 4)
+			_, err := clone.Seek(off, io.SeekStart)
+			if err == nil {
+				_, err = clone.Read(buf)
+			}
+			if err != nil {
+				errs <- err
+			} else if !bytes.Equal(buf, data[off:off+4]) {
+				errs <- fmt.Errorf("Clone read %d: expected %q, got %q", off, data[off:off+4], buf)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestDufsConcurrentReadAt(t *testing.T) {
+	data := make([]byte, 64<<10)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	server := newFakeDufs(t)
+	server.put("read-at.bin", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("read-at.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	_, err = f.Seek(100, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 128)
+
+	for i := 0; i < 128; i++ {
+		off := int64(i * 509 % len(data))
+		size := 1 + i*37%1024
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, size)
+			n, err := f.ReadAt(buf, off)
+			expected := data[off:min(off+int64(size), int64(len(data)))]
+			if n < size && err != io.EOF {
+				errs <- fmt.Errorf("ReadAt %d: short read of %d bytes should end with io.EOF, got %v", off, n, err)
+			} else if n == size && err != nil {
+				errs <- err
+			} else if !bytes.Equal(buf[:n], expected) {
+				errs <- fmt.Errorf("ReadAt %d: %d bytes mismatch", off, size)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if f.Tell() != 100 {
+		t.Fatal("ReadAt should not move the offset, got", f.Tell())
+	}
+}
+
+func TestDufsOpenChild(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("home/docs/readme.txt", []byte("hello child"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := dufs.Open("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child, err := dir.(*DufsFile).OpenChild("docs/readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if child.Name != "home/docs/readme.txt" {
+		t.Fatal("child name should be home/docs/readme.txt, got", child.Name)
+	}
+
+	data, err := io.ReadAll(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello child" {
+		t.Fatal("child content should be hello child, got", string(data))
+	}
+
+	for _, name := range []string{"../secret.txt", "docs/../../secret.txt", "/etc/passwd", "."} {
+		_, err = dir.(*DufsFile).OpenChild(name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Fatal(name, "should be rejected with fs.ErrInvalid, got", err)
+		}
+	}
+
+	_, err = child.OpenChild("nested.txt")
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("opening a child of a file should fail with fs.ErrInvalid, got", err)
+	}
+}
+
+func TestDufsContentType(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("image.png", []byte("not really a png"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contentType, err := dufs.ContentType("image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "image/png" {
+		t.Fatal("content type from the server should be image/png, got", contentType)
+	}
+
+	bare := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = nil
+		w.Header().Set("Content-Disposition", "inline")
+	}))
+	defer bare.Close()
+
+	dufs, err = NewDufsVFS(bare.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"image.png":   "image/png",
+		"unknown.ext": "application/octet-stream",
+	} {
+		contentType, err = dufs.ContentType(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != expected {
+			t.Fatal("content type of", name, "should be", expected, "got", contentType)
+		}
+	}
+}
+
+func TestDufsVerifyWrites(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("verify.txt", []byte("0123456789"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dufs.HttpClient
+	dufs.HttpClient = &http.Client{
+		Transport: &faultTransport{Method: http.MethodPatch, Truncate: 3},
+	}
+
+	file, err := dufs.Open("verify.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	_, err = f.WriteAt([]byte("abcdef"), 10)
+	if err != nil {
+		t.Fatal("a truncated PATCH goes unnoticed without VerifyWrites, got", err)
+	}
+
+	dufs.VerifyWrites = true
+
+	_, err = f.WriteAt([]byte("ghijkl"), 13)
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Fatal("a truncated PATCH should fail with io.ErrShortWrite, got", err)
+	}
+
+	dufs.HttpClient = client
+
+	_, err = f.WriteAt([]byte("mnopqr"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := server.get("verify.txt")
+	if string(data) != "0123456789abcghimnopqr" {
+		t.Fatal("unexpected content", string(data))
+	}
+}
+
+func TestDufsIfRange(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("changing.txt", []byte("0123456789"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("changing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	buf := make([]byte, 4)
+	_, err = f.ReadFullAt(buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.put("changing.txt", []byte("abcdefghijklmnop"))
+
+	_, err = f.ReadFullAt(buf, 4)
+	if !errors.Is(err, ErrFileChanged) {
+		t.Fatal("ReadFullAt of a changed file should fail with ErrFileChanged, got", err)
+	}
+
+	_, err = f.ReadFullAt(buf, 4)
+	if err != nil {
+		t.Fatal("ReadFullAt after a failure should read the new content, got", err)
+	}
+	if string(buf) != "efgh" {
+		t.Fatal("ReadFullAt should read efgh, got", string(buf))
+	}
+
+	_, err = f.Seek(8, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.put("changing.txt", []byte("ABCDEFGHIJKLMNOPQRST"))
+
+	_, err = f.Read(buf)
+	if !errors.Is(err, ErrFileChanged) {
+		t.Fatal("Read of a changed file should fail with ErrFileChanged, got", err)
+	}
+}
+
+func TestDufsReadLines(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("newline.txt", []byte("a\r\nb\nc\n"))
+	server.put("no-newline.txt", []byte("a\nb\nc"))
+	server.put("empty.txt", []byte{})
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		keep  bool
+		lines int
+	}{
+		{"newline.txt", false, 3},
+		{"newline.txt", true, 4},
+		{"no-newline.txt", false, 3},
+		{"no-newline.txt", true, 3},
+		{"empty.txt", false, 0},
+		{"empty.txt", true, 1},
+	} {
+		dufs.KeepTrailingEmptyLine = c.keep
+
+		file, err := dufs.Open(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines, err := file.(*DufsFile).ReadLines()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != c.lines {
+			t.Fatal(c.name, "should have", c.lines, "lines with KeepTrailingEmptyLine", c.keep, "got", strconv.Quote(fmt.Sprint(lines)))
+		}
+		if c.lines >= 3 && (lines[0] != "a" || lines[1] != "b" || lines[2] != "c") {
+			t.Fatal(c.name, "should read a, b and c, got", lines)
+		}
+	}
+}
+
+func TestDufsWithContext(t *testing.T) {
+	size := 1 << 20
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "inline")
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(make([]byte, 1000))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("hanging.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := file.(*DufsFile).WithContext(ctx)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	started := time.Now()
+	_, err = f.Read(make([]byte, size))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("cancelled Read should fail with context.Canceled, got", err)
+	}
+	if time.Since(started) > 5*time.Second {
+		t.Fatal("cancelled Read should return promptly, took", time.Since(started))
+	}
+
+	if len(dufs.ActiveOperations()) != 0 {
+		t.Fatal("cancelled Read should close its response, got", dufs.ActiveOperations())
+	}
+
+	_, err = f.Stat()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("Stat with a cancelled context should fail with context.Canceled, got", err)
+	}
+}
+
+func TestDufsCopyInto(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("copy-into.txt", []byte("old content that is longer"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("copy-into.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	_, err = f.Seek(10, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a plain io.Reader, as io.Copy prefers the WriteTo of a source like bytes.Buffer over ReadFrom
+	_, err = io.Copy(f, struct{ io.Reader }{bytes.NewBufferString("new content")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Tell() != 0 {
+		t.Fatal("io.Copy into a file should rewind it to 0, got", f.Tell())
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new content" {
+		t.Fatal("file should read back new content, got", string(data))
+	}
+}
+
+func TestDufsBasicAuth(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("private.txt", []byte("private"))
+
+	secured := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer secured.Close()
+
+	dufs, err := NewDufsVFS(secured.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.Stat("private.txt")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatal("Stat without credentials should fail with ErrUnauthorized, got", err)
+	}
+	err = dufs.Mkdir("dir", fs.ModePerm)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatal("Mkdir without credentials should fail with ErrUnauthorized, got", err)
+	}
+
+	dufs, err = NewDufsVFSWithAuth(secured.URL, "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("private.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "private" {
+		t.Fatal("file should read private, got", string(data))
+	}
+
+	err = dufs.Mkdir("dir", fs.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDufsOpenParent(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a/b/c.txt", []byte("c"))
+	server.put("a/b/d.txt", []byte("d"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string][]string{
+		"a/b/c.txt": {"c.txt", "d.txt"},
+		"/a":        {"a"},
+		"a/":        {"a"},
+	} {
+		parent, err := dufs.OpenParent(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := parent.(fs.ReadDirFile).ReadDir(-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			t.Fatal("parent of", name, "should list", expected, "got", names)
+		}
+	}
+}
+
+func TestDufsStatTTL(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("ttl.txt", []byte("1"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("ttl.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	_, err = f.CachedStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.put("ttl.txt", []byte("22"))
+
+	stat, err := f.CachedStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 1 {
+		t.Fatal("stat should be cached forever without StatTTL, got size", stat.Size())
+	}
+
+	dufs.StatTTL = 50 * time.Millisecond
+
+	stat, err = f.CachedStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 1 {
+		t.Fatal("stat should be cached within StatTTL, got size", stat.Size())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	stat, err = f.CachedStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 2 {
+		t.Fatal("stat should be refreshed after StatTTL, got size", stat.Size())
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"":               "",
+		"/":              "",
+		".":              "",
+		"a/b.txt":        "a/b.txt",
+		"/a/b.txt/":      "a/b.txt",
+		"a//./b.txt":     "a/b.txt",
+		"./a/b.txt":      "a/b.txt",
+		"ünï/cödé 名.txt": "ünï/cödé 名.txt",
+	} {
+		normalized, err := normalizeName(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if normalized != expected {
+			t.Fatalf("%q should normalize to %q, got %q", name, expected, normalized)
+		}
+	}
+
+	for _, name := range []string{"..", "../a", "a/../../b", "a/..", "\xff.txt"} {
+		_, err := normalizeName(name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Fatalf("%q should be rejected with fs.ErrInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestDufsNormalizedNames(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("ünï/cödé.txt", []byte("unicode"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"ünï/cödé.txt", "/ünï//cödé.txt", "./ünï/./cödé.txt"} {
+		stat, err := dufs.Stat(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if stat.Size() != 7 {
+			t.Fatal(name, "should stat ünï/cödé.txt, got size", stat.Size())
+		}
+	}
+
+	for _, name := range []string{"ünï", "/ünï/", "ünï//"} {
+		entries, err := dufs.ReadDir(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(entries) != 1 || entries[0].Name() != "cödé.txt" {
+			t.Fatal(name, "should list cödé.txt, got", entries)
+		}
+	}
+
+	err = dufs.MkdirAll("//new/./dir/", fs.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.dirs["new/dir"] {
+		t.Fatal("Mkdir should create new/dir")
+	}
+
+	escape := "ünï/../../escape.txt"
+	if _, err = dufs.Open(escape); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("Open should reject", escape, "got", err)
+	}
+	if _, err = dufs.Stat(escape); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("Stat should reject", escape, "got", err)
+	}
+	if err = dufs.Mkdir(escape, fs.ModePerm); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("Mkdir should reject", escape, "got", err)
+	}
+	if err = dufs.Remove(escape); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("Remove should reject", escape, "got", err)
+	}
+	if err = dufs.Rename("ünï/cödé.txt", escape); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("Rename should reject", escape, "got", err)
+	}
+}
+
+func TestDufsReadSmallBuffer(t *testing.T) {
+	data := make([]byte, 2<<20+7)
+	for i := range data {
+		data[i] = byte(i * 7 / 3)
+	}
+
+	server := newFakeDufs(t)
+	server.put("large.bin", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	// a plain io.Reader, so io.CopyBuffer reads with the small buffer instead of calling WriteTo
+	_, err = io.CopyBuffer(buf, struct{ io.Reader }{file}, make([]byte, 8191))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("reading with a small buffer should return the exact content, got", buf.Len(), "bytes")
+	}
+
+	ignoring := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "inline")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	}))
+	defer ignoring.Close()
+
+	dufs, err = NewDufsVFS(ignoring.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = dufs.Open("large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 10)
+	n, err := file.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(p) || !bytes.Equal(p, data[:len(p)]) || file.(*DufsFile).Tell() != int64(len(p)) {
+		t.Fatal("Read of a server ignoring Range should fill p only, got", n, "bytes and offset", file.(*DufsFile).Tell())
+	}
+}
+
+func TestDufsMkdirAll(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dufs.MkdirAll("a/b/c/d/", fs.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"a", "a/b", "a/b/c", "a/b/c/d"} {
+		stat, err := dufs.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stat.IsDir() {
+			t.Fatal(dir, "should be a directory")
+		}
+	}
+
+	err = dufs.MkdirAll("a/b/e", fs.ModePerm)
+	if err != nil {
+		t.Fatal("MkdirAll on a partially existing path should succeed, got", err)
+	}
+	if !server.dirs["a/b/e"] {
+		t.Fatal("MkdirAll should create a/b/e")
+	}
+
+	err = dufs.Mkdir("x/y", fs.ModePerm)
+	if err != nil || !server.dirs["x"] {
+		t.Fatal("Mkdir should create the parents like dufs, got", err)
+	}
+}
+
+func TestDufsMkdirAllWebDAV(t *testing.T) {
+	server := newFakeDufs(t)
+	server.WebDAV = true
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dufs.Mkdir("a/b/c", fs.ModePerm)
+	if !errors.Is(err, &StatusError{Code: http.StatusConflict}) {
+		t.Fatal("Mkdir should fail without parents on a WebDAV server, got", err)
+	}
+
+	err = dufs.MkdirAll("a/b/c", fs.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"a", "a/b", "a/b/c"} {
+		if !server.dirs[dir] {
+			t.Fatal("MkdirAll should create", dir, "one level at a time")
+		}
+	}
+}
+
+func TestDufsOverwrite(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("src.txt", []byte("source"))
+	server.put("dst.txt", []byte("destination"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dufs.CopyWithOpts("dst.txt", "src.txt", CopyOpts{Overwrite: OverwriteFail})
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("copy without overwrite should fail with fs.ErrExist, got", err)
+	}
+	if data, _ := server.get("dst.txt"); string(data) != "destination" {
+		t.Fatal("failed copy should keep the destination, got", string(data))
+	}
+
+	err = dufs.RenameWithOpts("src.txt", "dst.txt", RenameOpts{Overwrite: OverwriteFail})
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("rename without overwrite should fail with fs.ErrExist, got", err)
+	}
+
+	err = dufs.CopyWithOpts("dst.txt", "src.txt", CopyOpts{Overwrite: OverwriteReplace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := server.get("dst.txt"); string(data) != "source" {
+		t.Fatal("copy with overwrite should replace the destination, got", string(data))
+	}
+
+	server.put("dst.txt", []byte("destination"))
+
+	err = dufs.RenameWithOpts("src.txt", "dst.txt", RenameOpts{Overwrite: OverwriteReplace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := server.get("dst.txt"); string(data) != "source" {
+		t.Fatal("rename with overwrite should replace the destination, got", string(data))
+	}
+	if _, ok := server.get("src.txt"); ok {
+		t.Fatal("rename should remove the source")
+	}
+}
+
+func TestDufsExists(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("dir/file.txt", []byte("hello"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		exists bool
+		isDir  bool
+	}{
+		{"dir/file.txt", true, false},
+		{"dir", true, true},
+		{"dir/missing.txt", false, false},
+	} {
+		exists, isDir, err := dufs.Exists(c.name)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if exists != c.exists || isDir != c.isDir {
+			t.Fatal(c.name, "should be", c.exists, c.isDir, "got", exists, isDir)
+		}
+	}
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	dufs, err = NewDufsVFS(broken.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.RetryPolicy = nil
+
+	_, _, err = dufs.Exists("file.txt")
+	if err == nil {
+		t.Fatal("Exists should fail on a server error")
+	}
+
+	dufs, err = NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := dufs.Open("dir/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	requests := server.Requests.Load()
+	_, err = file.(*DufsFile).WithContext(ctx).Appender()
+	if !errors.Is(err, context.Canceled) || server.Requests.Load() != requests {
+		t.Fatal("the HEAD of Appender should be sent within the context of the file, got", err)
+	}
+}
+
+func TestDufsTruncate(t *testing.T) {
+	server := newFakeDufs(t)
+
+	content := make([]byte, 1024*1024)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	server.put("file.bin", content)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufsFile := file.(*DufsFile)
+
+	err = dufsFile.Truncate(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := server.get("file.bin")
+	if !bytes.Equal(data, content[:100]) {
+		t.Fatal("truncated file should keep the first 100 bytes, got", len(data), "bytes")
+	}
+
+	stat, err := dufsFile.CachedStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 100 {
+		t.Fatal("Stat after Truncate should report 100 bytes, got", stat.Size())
+	}
+
+	err = dufsFile.Truncate(110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = server.get("file.bin")
+	if !bytes.Equal(data, append(content[:100:100], make([]byte, 10)...)) {
+		t.Fatal("growing should pad with zeros, got", data)
+	}
+
+	err = dufsFile.Truncate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ = server.get("file.bin"); len(data) != 0 {
+		t.Fatal("truncating to 0 should empty the file, got", len(data), "bytes")
+	}
+}
+
+func TestDufsOpenFile(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("file.txt", []byte("hello world"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.OpenFile("file.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("O_EXCL on an existing file should fail with fs.ErrExist, got", err)
+	}
+	if data, _ := server.get("file.txt"); string(data) != "hello world" {
+		t.Fatal("failed O_EXCL should not touch the file, got", string(data))
+	}
+
+	_, err = dufs.OpenFile("missing.txt", os.O_RDONLY, 0)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("opening a missing file without O_CREATE should fail with fs.ErrNotExist, got", err)
+	}
+
+	file, err := dufs.OpenFile("file.txt", os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := server.get("file.txt"); len(data) != 0 {
+		t.Fatal("O_TRUNC should empty the file, got", string(data))
+	}
+
+	_, err = file.(*DufsFile).Read(make([]byte, 1))
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("Read on a O_WRONLY file should fail with fs.ErrPermission, got", err)
+	}
+
+	file, err = dufs.OpenFile("new.txt", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		_, err = file.(*DufsFile).Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, _ := server.get("new.txt"); string(data) != "a\nb\nc\n" {
+		t.Fatal("O_APPEND writes should be appended, got", string(data))
+	}
+
+	file, err = dufs.OpenFile("new.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).Write([]byte("x"))
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("Write on a O_RDONLY file should fail with fs.ErrPermission, got", err)
+	}
+}
+
+func TestDufsRemove(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a/b/removed.txt", []byte("removed"))
+	server.put("kept.txt", []byte("kept"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dufs.Remove("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.Stat("a/b/removed.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a directory should be removed with its content, got", err)
+	}
+	if _, ok := server.get("kept.txt"); !ok {
+		t.Fatal("kept.txt should be kept")
+	}
+
+	err = dufs.Remove("a")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("removing a missing file should fail with fs.ErrNotExist, got", err)
+	}
+}
+
+func TestDufsQueryToken(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("signed.txt", []byte("signed"))
+
+	var (
+		locker  sync.Mutex
+		queries []string
+	)
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locker.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		locker.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+
+	dufs, err := NewDufsVFS(server.URL + "?token=abc%2F1&expires=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.Stat("signed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locker.Lock()
+	defer locker.Unlock()
+
+	if len(queries) == 0 {
+		t.Fatal("requests should be sent")
+	}
+	for _, query := range queries {
+		if query != "json&token=abc%2F1&expires=2" {
+			t.Fatal("the token should be kept as is after the json flag, got", query)
+		}
+	}
+}
+
+func TestParseHTTPTime(t *testing.T) {
+	expected := time.Date(2024, 10, 17, 8, 30, 15, 0, time.UTC)
+
+	for _, value := range []string{
+		"Thu, 17 Oct 2024 08:30:15 GMT",
+		"Thursday, 17-Oct-24 08:30:15 GMT",
+		"Thu Oct 17 08:30:15 2024",
+		"Thu, 17 Oct 2024 10:30:15 +0200",
+		"Thu, 17 Oct 2024 08:30:15 UTC",
+		"2024-10-17T08:30:15Z",
+	} {
+		if mtime := parseHTTPTime(value); !mtime.Equal(expected) {
+			t.Fatalf("%q should be parsed as %s, got %s", value, expected, mtime)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "17/10/2024"} {
+		if mtime := parseHTTPTime(value); !mtime.IsZero() {
+			t.Fatalf("%q should be the zero time, got %s", value, mtime)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "not a date")
+		w.Header().Set("Content-Disposition", "inline")
+		_, _ = w.Write([]byte("undated"))
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := dufs.Stat("undated.txt")
+	if err != nil {
+		t.Fatal("an unparseable Last-Modified should not fail Stat, got", err)
+	}
+	if !stat.ModTime().IsZero() || stat.Size() != 7 {
+		t.Fatal("the file should be described with a zero mtime, got", stat.ModTime(), stat.Size())
+	}
+}
+
+func TestDufsChtimes(t *testing.T) {
+	dufs, err := NewDufsVFS(NewTestDufs(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dufs.Chtimes("stat.bin", time.Now())
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatal("dufs cannot set an mtime, got", err)
+	}
+}
+
+func TestDufsWriteFile(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.StrictFS = true
+
+	blob := bytes.Repeat([]byte("blob\x00\xff"), 1000)
+
+	err = dufs.WriteFile("blobs/known.bin", blob, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := dufs.Stat("blobs/known.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != int64(len(blob)) {
+		t.Fatal("the size should be", len(blob), "got", stat.Size())
+	}
+
+	data, err := dufs.ReadFile("blobs/known.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, blob) {
+		t.Fatal("the blob should be read back as written")
+	}
+
+	err = dufs.WriteFile("blobs/known.bin", nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := server.get("blobs/known.bin"); len(data) != 0 {
+		t.Fatal("the file should be emptied, got", len(data), "bytes")
+	}
+
+	err = dufs.WriteFile("../escape.bin", blob, 0644)
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("an invalid name should fail with fs.ErrInvalid, got", err)
+	}
+}
+
+func TestDufsReadChunked(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "inline")
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		var start, end int
+		_, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		if err != nil {
+			t.Error("a Range should be sent, got", r.Header.Get("Range"))
+			return
+		}
+		if start >= len(content) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		end = min(end, len(content)-1)
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, end))
+		w.WriteHeader(http.StatusPartialContent)
+		// flushing before the body sends it chunked, without Content-Length
+		w.(http.Flusher).Flush()
+		_, _ = w.Write(content[start : end+1])
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("chunked.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	stat, err := file.Stat()
+	if err != nil || stat.Size() >= 0 {
+		t.Fatal("the size should be unknown, got", stat, err)
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatal("Read should read until EOF, got", len(data), err)
+	}
+
+	p := make([]byte, 10)
+	n, err := file.(io.ReaderAt).ReadAt(p, 995)
+	if n != 5 || err != io.EOF || string(p[:n]) != "56789" {
+		t.Fatal("ReadAt should read the tail then EOF, got", string(p[:n]), err)
+	}
+	n, err = file.(io.ReaderAt).ReadAt(p, 2000)
+	if n != 0 || err != io.EOF {
+		t.Fatal("ReadAt past the end should be EOF, got", n, err)
+	}
+}
+
+func TestDufsConcurrentStatAndWrite(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("concurrent.bin", bytes.Repeat([]byte{'-'}, 64))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("concurrent.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, _ = f.Stat()
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = f.WriteAt([]byte{'+'}, int64(i))
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = f.ReadAt(make([]byte, 4), int64(i))
+			_ = f.Clone()
+		}()
+	}
+	wg.Wait()
+
+	data, _ := server.get("concurrent.bin")
+	if !bytes.Equal(data[:8], []byte("++++++++")) {
+		t.Fatal("every WriteAt should be applied, got", string(data))
+	}
+}
@@ -0,0 +1,64 @@
+package vfs
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	EnvDufsURL      = "DUFS_URL"
+	EnvDufsUser     = "DUFS_USER"
+	EnvDufsPass     = "DUFS_PASS"
+	EnvDufsInsecure = "DUFS_INSECURE"
+	EnvDufsTimeout  = "DUFS_TIMEOUT"
+)
+
+// NewDufsVFSFromEnv
+// Builds a DufsVFS from DUFS_URL (required), DUFS_USER and DUFS_PASS for basic auth,
+// DUFS_INSECURE to skip TLS verification and DUFS_TIMEOUT as a time.ParseDuration string
+func NewDufsVFSFromEnv() (*DufsVFS, error) {
+	root := os.Getenv(EnvDufsURL)
+	if root == "" {
+		return nil, errors.New("dufs: " + EnvDufsURL + " is not set")
+	}
+
+	dufs, err := NewDufsVFS(root)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := os.Getenv(EnvDufsUser), os.Getenv(EnvDufsPass)
+	if username == "" && password != "" {
+		return nil, errors.New("dufs: " + EnvDufsPass + " is set without " + EnvDufsUser)
+	}
+	if username != "" {
+		dufs.SetBasicAuth(username, password)
+	}
+
+	if value := os.Getenv(EnvDufsInsecure); value != "" {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("dufs: invalid %s %q: %w", EnvDufsInsecure, value, err)
+		}
+		if insecure {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			dufs.GetHttpClient().Transport = transport
+		}
+	}
+
+	if value := os.Getenv(EnvDufsTimeout); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("dufs: invalid %s %q: %w", EnvDufsTimeout, value, err)
+		}
+		dufs.GetHttpClient().Timeout = timeout
+	}
+
+	return dufs, nil
+}
@@ -0,0 +1,58 @@
+package vfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewDufsVFSFromEnv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Disposition", "inline")
+	}))
+	defer server.Close()
+
+	t.Setenv(EnvDufsURL, "")
+	_, err := NewDufsVFSFromEnv()
+	if err == nil {
+		t.Fatal("missing " + EnvDufsURL + " should fail")
+	}
+
+	t.Setenv(EnvDufsURL, server.URL+"/")
+	t.Setenv(EnvDufsUser, "admin")
+	t.Setenv(EnvDufsPass, "secret")
+	t.Setenv(EnvDufsInsecure, "true")
+	t.Setenv(EnvDufsTimeout, "3s")
+
+	dufs, err := NewDufsVFSFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dufs.Root != server.URL {
+		t.Fatal("root should be", server.URL, "got", dufs.Root)
+	}
+	if dufs.GetHttpClient().Timeout != 3*time.Second {
+		t.Fatal("timeout should be 3s, got", dufs.GetHttpClient().Timeout)
+	}
+	if transport, ok := dufs.GetHttpClient().Transport.(*http.Transport); !ok || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Fatal("TLS verification should be skipped")
+	}
+
+	_, err = dufs.Stat("file.txt")
+	if err != nil {
+		t.Fatal("requests should carry the basic auth, got", err)
+	}
+
+	t.Setenv(EnvDufsTimeout, "soon")
+	_, err = NewDufsVFSFromEnv()
+	if err == nil {
+		t.Fatal("invalid " + EnvDufsTimeout + " should fail")
+	}
+}
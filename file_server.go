@@ -0,0 +1,91 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// FileSystem
+// Serves a VFS with http.FileServer, such as a dufs tree behind a Go HTTP server
+type FileSystem struct {
+	VFS VFS
+}
+
+func NewFileSystem(vfs VFS) *FileSystem {
+	return &FileSystem{
+		VFS: vfs,
+	}
+}
+
+// Open
+// name is the slash-separated path given by http.FileServer, cleaned to a fs.ValidPath name, "/" being the root "."
+func (d *FileSystem) Open(name string) (http.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	file, err := d.VFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpFile{
+		File: file,
+	}, nil
+}
+
+// httpFile bridges a fs.File to http.File, the directory is listed once and Readdir pages through it
+type httpFile struct {
+	fs.File
+
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *httpFile) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := d.File.(io.Seeker)
+	if !ok {
+		return 0, errors.New("dufs: file is not seekable")
+	}
+	return seeker.Seek(offset, whence)
+}
+
+// Readdir
+// Same as os.File.Readdir, all the rest with count <= 0, otherwise at most count and io.EOF at the end
+func (d *httpFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.listed {
+		dir, ok := d.File.(fs.ReadDirFile)
+		if !ok {
+			return nil, fs.ErrInvalid
+		}
+
+		entries, err := dir.ReadDir(-1)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries = entries
+		d.listed = true
+	}
+
+	entries, err := readDirPage(&d.entries, count, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
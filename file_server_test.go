@@ -0,0 +1,81 @@
+package vfs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFileSystem(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("docs/readme.txt", []byte("served through http.FileServer"))
+	server.put("docs/other.txt", []byte("other"))
+
+	for _, strict := range []bool{false, true} {
+		dufs, err := NewDufsVFS(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dufs.StrictFS = strict
+
+		testFileSystem(t, dufs)
+	}
+}
+
+func testFileSystem(t *testing.T, dufs *DufsVFS) {
+	front := httptest.NewServer(http.FileServer(NewFileSystem(dufs)))
+	defer front.Close()
+
+	get := func(link string, header http.Header) (int, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, front.URL+link, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("/docs/readme.txt", nil)
+	if status != http.StatusOK || body != "served through http.FileServer" {
+		t.Fatal("file should be served, got", status, body)
+	}
+
+	status, body = get("/docs/readme.txt", http.Header{"Range": {"bytes=7-13"}})
+	if status != http.StatusPartialContent || body != "through" {
+		t.Fatal("range of the file should be served, got", status, body)
+	}
+
+	status, body = get("/docs/", nil)
+	if status != http.StatusOK || !strings.Contains(body, "readme.txt") || !strings.Contains(body, "other.txt") {
+		t.Fatal("directory should be listed, got", status, body)
+	}
+
+	status, body = get("/", nil)
+	if status != http.StatusOK || !strings.Contains(body, "docs/") {
+		t.Fatal("the root should be listed, strict:", dufs.StrictFS, "got", status, body)
+	}
+
+	status, _ = get("/docs/missing.txt", nil)
+	if status != http.StatusNotFound {
+		t.Fatal("missing file should be 404, got", status)
+	}
+}
@@ -0,0 +1,87 @@
+package vfs
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestDufsFSCompliance(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a.txt", []byte("a"))
+	server.put("dir/b.txt", []byte("bb"))
+	server.put("dir/sub/c.txt", []byte("ccc"))
+	server.put("empty.txt", []byte{})
+	server.mkdir("empty-dir")
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SetLogger(DiscardLogger)
+	dufs.StrictFS = true
+
+	err = fstest.TestFS(dufs, "a.txt", "dir/b.txt", "dir/sub/c.txt", "empty.txt", "empty-dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachingFSCompliance(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a.txt", []byte("a"))
+	server.put("dir/b.txt", []byte("bb"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SetLogger(DiscardLogger)
+	dufs.StrictFS = true
+
+	cache := Caching(dufs, CachingOpts{})
+	_, err = cache.ReadFile("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fstest.TestFS(cache, "a.txt", "dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDufsListingMTime(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a.txt", []byte("a"))
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 678e6, time.UTC)
+	server.locker.Lock()
+	server.mtimes["a.txt"] = mtime
+	server.locker.Unlock()
+
+	for _, strict := range []bool{false, true} {
+		dufs, err := NewDufsVFS(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dufs.SetLogger(DiscardLogger)
+		dufs.StrictFS = strict
+
+		entries, err := dufs.ReadDir(".")
+		if err != nil || len(entries) != 1 {
+			t.Fatal("ReadDir should list a.txt, got", entries, err)
+		}
+		info, err := entries[0].Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := mtime
+		if strict {
+			want = mtime.Truncate(time.Second)
+		}
+		if !info.ModTime().Equal(want) {
+			t.Fatal("the listed mtime with StrictFS", strict, "should be", want, "got", info.ModTime())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob
+// Implements fs.GlobFS: each directory a segment of pattern may match in is listed once,
+// and its entries are matched with path.Match, so "a/*/b" lists a and then its subdirectories
+func (d *DufsVFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	normalized, err := normalizeName(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := strings.Split(normalized, "/")
+	dirs := []string{"."}
+
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		var matches []string
+		for _, dir := range dirs {
+			entries, err := d.ReadDir(dir)
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			for _, entry := range entries {
+				if !last && !entry.IsDir() {
+					continue
+				}
+				if ok, _ := path.Match(segment, entry.Name()); ok {
+					matches = append(matches, path.Join(dir, entry.Name()))
+				}
+			}
+		}
+
+		dirs = matches
+		if len(dirs) == 0 {
+			return nil, nil
+		}
+	}
+
+	sort.Strings(dirs)
+
+	return dirs, nil
+}
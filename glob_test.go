@@ -0,0 +1,56 @@
+package vfs
+
+import (
+	"io/fs"
+	"path"
+	"slices"
+	"testing"
+)
+
+func TestDufsGlob(t *testing.T) {
+	server := newFakeDufs(t)
+	for _, name := range []string{
+		"photos/a.jpg", "photos/b.jpg", "photos/ab.jpg", "photos/c.png",
+		"albums/2023/cover.jpg", "albums/2024/cover.jpg", "albums/2024/back.jpg", "albums/notes.txt",
+	} {
+		server.put(name, []byte(name))
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pattern, expected := range map[string][]string{
+		"photos/*.jpg":        {"photos/a.jpg", "photos/ab.jpg", "photos/b.jpg"},
+		"photos/?.jpg":        {"photos/a.jpg", "photos/b.jpg"},
+		"photos/[ac].*":       {"photos/a.jpg", "photos/c.png"},
+		"photos/[^a]*":        {"photos/b.jpg", "photos/c.png"},
+		"albums/*/cover.jpg":  {"albums/2023/cover.jpg", "albums/2024/cover.jpg"},
+		"*/*.txt":             {"albums/notes.txt"},
+		"albums/notes.txt/*":  nil,
+		"photos/*.gif":        nil,
+		"missing/*/cover.jpg": nil,
+	} {
+		matches, err := dufs.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(matches, expected) {
+			t.Fatal("Glob of", pattern, "should match", expected, "got", matches)
+		}
+
+		matches, err = fs.Glob(dufs, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(matches, expected) {
+			t.Fatal("fs.Glob of", pattern, "should match", expected, "got", matches)
+		}
+	}
+
+	_, err = dufs.Glob("photos/[")
+	if err != path.ErrBadPattern {
+		t.Fatal("a malformed pattern should fail with path.ErrBadPattern, got", err)
+	}
+}
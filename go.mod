@@ -1,3 +1,5 @@
 module github.com/allape/go-http-vfs
 
 go 1.23.2
+
+require golang.org/x/net v0.35.0
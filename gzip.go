@@ -0,0 +1,59 @@
+package vfs
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var ErrCompressedRange = errors.New("dufs: server compressed a ranged response")
+
+// gzipBody decompresses a response body, closing it along with the gzip reader
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (d *gzipBody) Close() error {
+	_ = d.Reader.Close()
+	return d.body.Close()
+}
+
+// acceptGzip returns a copy of headers asking for gzip, unless it is a Range request or already sets Accept-Encoding
+func acceptGzip(headers http.Header) http.Header {
+	if headers.Get("Range") != "" || headers.Get("Accept-Encoding") != "" {
+		return headers
+	}
+	headers = headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Accept-Encoding", "gzip")
+	return headers
+}
+
+// decompress replaces the body of a gzip response with its decompressed content,
+// a compressed partial response fails with ErrCompressedRange as its offsets are not the ones of the file
+func decompress(resp *http.Response) error {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		return ErrCompressedRange
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	resp.Body = &gzipBody{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return nil
+}
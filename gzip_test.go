@@ -0,0 +1,85 @@
+package vfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDufsEnableGzip(t *testing.T) {
+	content := []byte(strings.Repeat("compressible text\n", 1000))
+
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Accept-Encoding")
+		encodings = append(encodings, encoding)
+
+		w.Header().Set("Content-Disposition", "attachment")
+		w.Header().Set("Content-Type", "text/plain")
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start, end int
+			_, _ = fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			end = min(end, len(content)-1)
+			w.Header().Set("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(end)+"/"+strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(content[start : end+1])
+			return
+		}
+
+		if encoding == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			_, _ = writer.Write(content)
+			_ = writer.Close()
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.EnableGzip = true
+
+	file, err := dufs.Open("text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	_, err = file.(*DufsFile).WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Fatal("WriteTo should decompress the content, got", buf.Len(), "bytes")
+	}
+	if encodings[len(encodings)-1] != "gzip" {
+		t.Fatal("WriteTo should ask for gzip, got", encodings[len(encodings)-1])
+	}
+
+	p := make([]byte, 10)
+	_, err = file.(*DufsFile).ReadFullAt(p, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p, content[100:110]) {
+		t.Fatal("ranged read mismatch, got", string(p))
+	}
+	if encodings[len(encodings)-1] == "gzip" {
+		t.Fatal("ranged reads should not ask for gzip")
+	}
+}
@@ -0,0 +1,60 @@
+package vfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+var ErrHashUnsupported = fmt.Errorf("dufs: hash not supported: %w", errors.ErrUnsupported)
+
+// Hash
+// Returns the sha256 of a file computed by the server with the ?hash query, without downloading it.
+// Fails with ErrHashUnsupported if the server answers with anything but a hash, as dufs before 0.38 sends the file itself
+func (d *DufsVFS) Hash(name string) (HashString, error) {
+	href, err := d.appendToRoot(name)
+	if err != nil {
+		return "", err
+	}
+	href.addQuery("hash", "")
+
+	req, err := http.NewRequest(http.MethodGet, href.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	logFields(d.GetLogger(), "Hash", "method", http.MethodGet, "url", href.String(), "status", resp.StatusCode)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", statusError(resp)
+	}
+
+	if resp.Header.Get("Content-Disposition") != "" || strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return "", &fs.PathError{Op: "hash", Path: name, Err: ErrHashUnsupported}
+	}
+
+	// a sha256 is 64 hex digits, reading one more byte tells a hash from the start of a longer body
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(hex.EncodedLen(sha256.Size))+1))
+	if err != nil {
+		return "", err
+	}
+
+	hash := strings.ToLower(strings.TrimSpace(string(data)))
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != hex.EncodedLen(sha256.Size) {
+		return "", &fs.PathError{Op: "hash", Path: name, Err: ErrHashUnsupported}
+	}
+
+	return HashString(hash), nil
+}
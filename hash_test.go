@@ -0,0 +1,52 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestDufsHash(t *testing.T) {
+	hash, filename, _, err := CreateTestData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeTestData(t, string(filename))
+
+	dufs, err := NewDufsVFS(NewTestDufs(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remoteHash, err := dufs.Hash(string(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remoteHash != hash {
+		t.Fatal("the hash of the server should be", hash, "got", remoteHash)
+	}
+
+	_, err = dufs.Hash("missing.bin")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("the hash of a missing file should fail with fs.ErrNotExist, got", err)
+	}
+}
+
+func TestDufsHashUnsupported(t *testing.T) {
+	server := newFakeDufs(t)
+	server.NoHash = true
+	server.put("file.txt", []byte("no hash here"))
+	server.put("dir/child.txt", []byte("child"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"file.txt", "dir"} {
+		_, err = dufs.Hash(name)
+		if !errors.Is(err, ErrHashUnsupported) || !errors.Is(err, errors.ErrUnsupported) {
+			t.Fatal(name, "should fail with ErrHashUnsupported, got", err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package vfs
+
+import (
+	"html"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	htmlAnchorPattern = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*(?:"([^"]*)"|'([^']*)')[^>]*>`)
+	htmlAnchorEnd     = regexp.MustCompile(`(?i)</a\s*>`)
+	htmlTagPattern    = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlDatePatterns  = []struct {
+		pattern *regexp.Regexp
+		layouts []string
+	}{
+		{regexp.MustCompile(`\d{1,2}-[A-Za-z]{3}-\d{4} \d{1,2}:\d{2}(?::\d{2})?`), []string{"2-Jan-2006 15:04", "2-Jan-2006 15:04:05"}},
+		{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{1,2}:\d{2}(?::\d{2})?`), []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02T15:04:05"}},
+		{regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{1,2}:\d{2}(?::\d{2})?`), []string{"2006/01/02 15:04", "2006/01/02 15:04:05"}},
+	}
+	htmlSizePattern = regexp.MustCompile(`(?i)(?:^|\s)(\d+(?:\.\d+)?)\s*([KMGTPE]?)(?:i?B|bytes)?(?:\s|$)`)
+)
+
+// parseHumanSize converts sizes like "1234", "1.2K", "3.5 MB" or "4 GiB" to bytes, with 1024 based units
+func parseHumanSize(size string) (int64, bool) {
+	match := htmlSizePattern.FindStringSubmatch(" " + strings.TrimSpace(size) + " ")
+	if match == nil {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if unit := strings.ToUpper(match[2]); unit != "" {
+		value *= float64(int64(1) << (10 * (strings.Index("KMGTPE", unit) + 1)))
+	}
+
+	return int64(value), true
+}
+
+// parseHTMLListing scrapes the entries of an HTML directory index served at base,
+// links not pointing to a direct child of base, such as the parent directory or sorting links, are skipped.
+// The mtime and size of an entry are taken from the text following its link.
+func parseHTMLListing(data []byte, base *url.URL) []*HttpFileInfo {
+	dir := *base
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+		dir.RawPath = ""
+	}
+	dir.RawQuery = ""
+
+	content := string(data)
+	anchors := htmlAnchorPattern.FindAllStringSubmatchIndex(content, -1)
+
+	var infos []*HttpFileInfo
+	indexes := map[string]int{}
+
+	for i, anchor := range anchors {
+		var href string
+		if anchor[2] >= 0 {
+			href = content[anchor[2]:anchor[3]]
+		} else {
+			href = content[anchor[4]:anchor[5]]
+		}
+		ref, err := url.Parse(html.UnescapeString(href))
+		if err != nil {
+			continue
+		}
+
+		target := dir.ResolveReference(ref)
+		if target.Host != dir.Host || target.RawQuery != "" || target.Fragment != "" {
+			continue
+		}
+
+		isDir := strings.HasSuffix(target.Path, "/")
+		name := strings.TrimSuffix(target.Path, "/")
+		parent := path.Dir(name)
+		if parent != "/" {
+			parent += "/"
+		}
+		if name == "" || parent != dir.Path {
+			continue
+		}
+		name = path.Base(name)
+
+		tail := content[anchor[1]:]
+		if i+1 < len(anchors) {
+			tail = content[anchor[1]:anchors[i+1][0]]
+		}
+		if end := htmlAnchorEnd.FindStringIndex(tail); end != nil {
+			tail = tail[end[1]:]
+		}
+		tail = html.UnescapeString(htmlTagPattern.ReplaceAllString(tail, " "))
+
+		var mtime time.Time
+		for _, date := range htmlDatePatterns {
+			loc := date.pattern.FindStringIndex(tail)
+			if loc == nil {
+				continue
+			}
+			for _, layout := range date.layouts {
+				if mtime, err = time.Parse(layout, tail[loc[0]:loc[1]]); err == nil {
+					tail = tail[:loc[0]] + " " + tail[loc[1]:]
+					break
+				}
+			}
+			break
+		}
+
+		size := int64(0)
+		if !isDir {
+			size, _ = parseHumanSize(tail)
+		}
+
+		if index, ok := indexes[name]; ok {
+			if infos[index].mtime.IsZero() {
+				infos[index].mtime = mtime
+			}
+			if infos[index].size == 0 {
+				infos[index].size = size
+			}
+			continue
+		}
+
+		indexes[name] = len(infos)
+		infos = append(infos, &HttpFileInfo{
+			name:  name,
+			size:  size,
+			mode:  fs.ModePerm,
+			mtime: mtime,
+			isDir: isDir,
+		})
+	}
+
+	return infos
+}
@@ -0,0 +1,100 @@
+package vfs
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const dufsHTMLListing = `<!DOCTYPE html>
+<html>
+<head><title>Index of /docs/</title></head>
+<body>
+<div class="breadcrumb"><a href="/">root</a> / <a href="/docs/">docs</a></div>
+<table class="paths-table">
+<thead><tr>
+<th><a href="?sort=name&amp;order=desc">Name</a></th><th>Last modified</th><th>Size</th>
+</tr></thead>
+<tbody>
+<tr><td class="path"><a href="/docs/../">..</a></td></tr>
+<tr><td class="path"><a href="/docs/guide/">guide/</a></td><td class="cell-mtime">2024-10-17 08:30:15</td><td class="cell-size"></td></tr>
+<tr><td class="path"><a href="/docs/read%20me.md">read me.md</a></td><td class="cell-mtime">2024-10-16 22:01:00</td><td class="cell-size">1.5 KB</td></tr>
+<tr><td class="path"><a href="/docs/tiny.txt">tiny.txt</a></td><td class="cell-mtime">2024-01-02 03:04:05</td><td class="cell-size">12 B</td></tr>
+</tbody>
+</table>
+</body>
+</html>`
+
+func TestParseHumanSize(t *testing.T) {
+	cases := map[string]int64{
+		"1234":    1234,
+		"12 B":    12,
+		"1.5 KB":  1536,
+		"1.2K":    1228,
+		"3M":      3 << 20,
+		"2 GiB":   2 << 30,
+		"0 bytes": 0,
+	}
+	for size, expected := range cases {
+		n, ok := parseHumanSize(size)
+		if !ok || n != expected {
+			t.Fatalf("%q should be %d, got %d", size, expected, n)
+		}
+	}
+
+	if _, ok := parseHumanSize("-"); ok {
+		t.Fatal(`"-" should not be a size`)
+	}
+}
+
+func TestDufsHTMLListing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(dufsHTMLListing))
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.ReadDir("/docs")
+	if !errors.Is(err, ErrNoJSONListing) {
+		t.Fatal("HTML listing should fail with ErrNoJSONListing, got", err)
+	}
+
+	dufs.HTMLListing = true
+
+	entries, err := dufs.ReadDir("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name  string
+		isDir bool
+		size  int64
+		mtime time.Time
+	}{
+		{"guide", true, 0, time.Date(2024, 10, 17, 8, 30, 15, 0, time.UTC)},
+		{"read me.md", false, 1536, time.Date(2024, 10, 16, 22, 1, 0, 0, time.UTC)},
+		{"tiny.txt", false, 12, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatal("listing should contain", len(expected), "entries, got", entries)
+	}
+
+	for i, e := range expected {
+		info, err := entries[i].Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name() != e.name || info.IsDir() != e.isDir || info.Size() != e.size || !info.ModTime().Equal(e.mtime) {
+			t.Fatalf("entry %d mismatch, expected %v, got %s %v %d %s", i, e, info.Name(), info.IsDir(), info.Size(), info.ModTime())
+		}
+	}
+}
@@ -2,6 +2,7 @@ package vfs
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"io/fs"
@@ -9,12 +10,21 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-var DiscardLogger = log.New(io.Discard, "", 0)
+var (
+	DiscardLogger       = log.New(io.Discard, "", 0)
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
 
+// VFS
+// A fs.FS over HTTP. By default names are normalized and files opened lazily, which fs.FS does not allow,
+// set the StrictFS of HttpVFS for the io/fs contract, such as for testing/fstest.TestFS
 type VFS interface {
 	fs.StatFS
 	fs.ReadDirFS
@@ -22,8 +32,11 @@ type VFS interface {
 	fs.StatFS
 	SetHttpClient(client *http.Client)
 	GetHttpClient() *http.Client
-	SetLogger(logger *log.Logger)
-	GetLogger() *log.Logger
+	SetLogger(logger Logger)
+	GetLogger() Logger
+	Do(req *http.Request) (*http.Response, error)
+	ActiveOperations() []Operation
+	Cancel(id uint64) bool
 }
 
 type File interface {
@@ -46,6 +59,9 @@ type HttpFileInfo struct {
 	mode  fs.FileMode
 	mtime time.Time
 	isDir bool
+
+	// header is the header of the response the info was made of, nil for an entry of a listing
+	header http.Header
 }
 
 func (d *HttpFileInfo) Name() string {
@@ -57,6 +73,9 @@ func (d *HttpFileInfo) Size() int64 {
 }
 
 func (d *HttpFileInfo) Mode() fs.FileMode {
+	if d.isDir {
+		return d.mode | fs.ModeDir
+	}
 	return d.mode
 }
 
@@ -68,8 +87,29 @@ func (d *HttpFileInfo) IsDir() bool {
 	return d.isDir
 }
 
+// Sys
+// Returns the http.Header of the response of the Stat, such as for the ETag, the Content-Type or the X- headers,
+// or nil for an entry of a listing
 func (d *HttpFileInfo) Sys() any {
-	return nil
+	if d.header == nil {
+		return nil
+	}
+	return d.header
+}
+
+// Header
+// The header of the response of the Stat, nil for an entry of a listing, not to be modified
+func (d *HttpFileInfo) Header() http.Header {
+	return d.header
+}
+
+// baseName is the last segment of name, "." for the root, as the Name of a fs.FileInfo
+func baseName(name string) string {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return "."
+	}
+	return path.Base(name)
 }
 
 type HttpDirEntry struct {
@@ -86,7 +126,7 @@ func (d *HttpDirEntry) IsDir() bool {
 }
 
 func (d *HttpDirEntry) Type() fs.FileMode {
-	return d.info.Mode()
+	return d.info.Mode().Type()
 }
 
 func (d *HttpDirEntry) Info() (fs.FileInfo, error) {
@@ -106,8 +146,48 @@ type HttpVFS struct {
 	Root     string
 	OpenFunc OpenFunc
 
-	Logger     *log.Logger
+	// StrictFS makes the VFS follow the io/fs contract: Open, Stat, ReadDir and ReadFile fail with fs.ErrInvalid
+	// for a name not valid for fs.ValidPath instead of normalizing names like "/a//b" or "a/./b",
+	// and Open sends a Stat to fail with fs.ErrNotExist for a missing file instead of opening lazily.
+	// It is off by default, where the VFS is not a compliant fs.FS and only passes testing/fstest.TestFS with it on
+	StrictFS bool
+
+	Logger     Logger
 	HttpClient *http.Client
+
+	// OnlineTimeout bounds the request of Online, DefaultOnlineTimeout if 0
+	OnlineTimeout time.Duration
+
+	// PrefetchWidth is the max number of directory listings fetched concurrently by WalkDir, 0 means serial
+	PrefetchWidth int
+	// PrefetchDepth is how many levels below the visited directory WalkDir fetches ahead
+	PrefetchDepth int
+
+	RetryPolicy *RetryPolicy
+
+	// Headers are added to every request that does not set them itself, such as an Authorization for a proxy
+	Headers http.Header
+
+	// Signer is called with every request just before it is sent, retries included, with its final URL and headers,
+	// such as to add the signature a gateway requires. The request fails with the error it returns
+	Signer func(req *http.Request) error
+
+	// RateLimit caps the bytes per second sent with request bodies, and read from response bodies, 0 is unlimited
+	RateLimit int64
+
+	// Observer is told about every request, such as to export metrics, nil disables it
+	Observer Observer
+
+	// Tracer starts a span for each file operation, such as an OpenTelemetry tracer wrapped in a Tracer, nil disables tracing
+	Tracer Tracer
+
+	username string
+	password string
+
+	uploadBucket   tokenBucket
+	downloadBucket tokenBucket
+
+	operations operations
 }
 
 func NewHttpVFS(root, tag string) (*HttpVFS, error) {
@@ -130,53 +210,269 @@ func (d *HttpVFS) GetHttpClient() *http.Client {
 	return d.HttpClient
 }
 
-func (d *HttpVFS) SetLogger(logger *log.Logger) {
+// SetMaxRedirects
+// Replaces HttpClient with a copy failing with ErrTooManyRedirects instead of following more than n redirects,
+// the client is copied as it may be shared. A client set afterward with SetHttpClient keeps its own policy
+func (d *HttpVFS) SetMaxRedirects(n int) {
+	client := *d.GetHttpClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > n {
+			return ErrTooManyRedirects
+		}
+		return nil
+	}
+	d.HttpClient = &client
+}
+
+// Tripperware wraps a RoundTripper, such as to sign, log or count the requests
+type Tripperware func(next http.RoundTripper) http.RoundTripper
+
+// Use
+// Replaces HttpClient with a copy whose Transport, http.DefaultTransport if nil, is wrapped with tripperware,
+// the first one sees the requests first. The client is copied as it may be shared, such as with a Sub or http.DefaultClient.
+// A client set afterward with SetHttpClient keeps its own Transport
+func (d *HttpVFS) Use(tripperware ...Tripperware) {
+	client := *d.GetHttpClient()
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(tripperware) - 1; i >= 0; i-- {
+		transport = tripperware[i](transport)
+	}
+
+	client.Transport = transport
+	d.HttpClient = &client
+}
+
+// signError is the failure of the Signer, never retried as it would fail the same way again
+type signError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (d *signError) Error() string {
+	return "dufs: sign " + d.Method + " " + d.URL + ": " + d.Err.Error()
+}
+
+func (d *signError) Unwrap() error {
+	return d.Err
+}
+
+// RoundTripperFunc is a function used as an http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (d RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return d(req)
+}
+
+// SetDefaultHeader
+// Sets a header of Headers, not safe to call while requests are sent
+func (d *HttpVFS) SetDefaultHeader(key, value string) {
+	if d.Headers == nil {
+		d.Headers = http.Header{}
+	}
+	d.Headers.Set(key, value)
+}
+
+// SetBasicAuth
+// Sends the credentials with every request that does not carry its own Authorization header
+func (d *HttpVFS) SetBasicAuth(username, password string) {
+	d.username = username
+	d.password = password
+}
+
+func (d *HttpVFS) SetLogger(logger Logger) {
 	d.Logger = logger
 }
 
-func (d *HttpVFS) GetLogger() *log.Logger {
+func (d *HttpVFS) GetLogger() Logger {
 	if d.Logger == nil {
 		return DiscardLogger
 	}
+	if logger, ok := d.Logger.(*log.Logger); ok && logger == nil {
+		return DiscardLogger
+	}
 	return d.Logger
 }
 
+// Do
+// Sends the request with the HttpClient and tracks it as an active operation until the response body is closed,
+// transient failures are retried according to RetryPolicy
+func (d *HttpVFS) Do(req *http.Request) (*http.Response, error) {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for key, values := range d.Headers {
+		if _, ok := req.Header[key]; !ok {
+			req.Header[key] = append([]string(nil), values...)
+		}
+	}
+	if d.username != "" || d.password != "" {
+		if req.Header.Get("Authorization") == "" {
+			req.SetBasicAuth(d.username, d.password)
+		}
+	}
+
+	span := spanFromContext(req.Context())
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+
+	resp, err := d.doWithRetry(req)
+	if err == nil {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+	}
+	return resp, err
+}
+
+func (d *HttpVFS) do(req *http.Request) (*http.Response, error) {
+	if d.Signer != nil {
+		err := d.Signer(req)
+		if err != nil {
+			return nil, &signError{Method: req.Method, URL: req.URL.String(), Err: err}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	id := d.operations.add(req, cancel)
+
+	start := time.Now()
+	var bytes atomic.Int64
+
+	req = req.WithContext(ctx)
+	if d.RateLimit > 0 && req.Body != nil && req.Body != http.NoBody {
+		req.Body = &rateLimitedBody{ReadCloser: req.Body, ctx: ctx, bucket: &d.uploadBucket, rate: d.RateLimit}
+	}
+	if d.Observer != nil && req.Body != nil && req.Body != http.NoBody {
+		req.Body = &countingBody{ReadCloser: req.Body, n: &bytes}
+	}
+
+	resp, err := d.GetHttpClient().Do(req)
+	if err != nil {
+		d.operations.remove(id)
+		if d.Observer != nil {
+			d.Observer.OnRequest(req.Method, 0, time.Since(start), bytes.Load())
+		}
+		return nil, err
+	}
+
+	if d.RateLimit > 0 {
+		resp.Body = &rateLimitedBody{ReadCloser: resp.Body, ctx: ctx, bucket: &d.downloadBucket, rate: d.RateLimit}
+	}
+	if d.Observer != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, n: &bytes}
+	}
+
+	resp.Body = &operationBody{
+		ReadCloser: resp.Body,
+		done: func() {
+			d.operations.remove(id)
+			if d.Observer != nil {
+				d.Observer.OnRequest(req.Method, resp.StatusCode, time.Since(start), bytes.Load())
+			}
+		},
+	}
+
+	return resp, nil
+}
+
+func (d *HttpVFS) ActiveOperations() []Operation {
+	return d.operations.list()
+}
+
+// Cancel
+// Aborts the in-flight operation with the given id, returns false if no such operation is active
+func (d *HttpVFS) Cancel(id uint64) bool {
+	return d.operations.cancel(id)
+}
+
 func (d *HttpVFS) Open(name string) (fs.File, error) {
 	if d.OpenFunc == nil {
 		return nil, errors.New("func Open is not implemented")
 	}
-	return d.OpenFunc(name)
+	err := d.checkName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := d.OpenFunc(name)
+	if err != nil || !d.StrictFS {
+		return file, err
+	}
+	_, err = file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return file, nil
+}
+
+func (d *HttpVFS) isStrictFS() bool {
+	return d.StrictFS
+}
+
+// checkName rejects a name not valid for fs.ValidPath if StrictFS is set
+func (d *HttpVFS) checkName(op, name string) error {
+	if d.StrictFS && !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
 }
 
+// ReadDir
+// Returns the entries sorted by name, like fs.ReadDir
 func (d *HttpVFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	err := d.checkName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
 	file, err := d.OpenFunc(name)
 	if err != nil {
 		return nil, err
 	}
-	if f, ok := file.(fs.ReadDirFile); ok {
-		return f.ReadDir(-1)
+	f, ok := file.(fs.ReadDirFile)
+	if !ok {
+		return nil, fs.ErrInvalid
+	}
+	entries, err := f.ReadDir(-1)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fs.ErrInvalid
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
 }
 
 func (d *HttpVFS) ReadFile(name string) ([]byte, error) {
+	err := d.checkName("readfile", name)
+	if err != nil {
+		return nil, err
+	}
 	file, err := d.OpenFunc(name)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		_ = file.Close()
+	}()
 
-	var buf []byte
-	writer := bytes.NewBuffer(buf)
+	writer := bytes.NewBuffer(nil)
 
 	_, err = io.Copy(writer, file)
 	if err != nil {
 		return nil, err
 	}
 
-	return buf, nil
+	return writer.Bytes(), nil
 }
 
 func (d *HttpVFS) Stat(name string) (fs.FileInfo, error) {
+	err := d.checkName("stat", name)
+	if err != nil {
+		return nil, err
+	}
 	file, err := d.OpenFunc(name)
 	if err != nil {
 		return nil, err
@@ -185,36 +481,64 @@ func (d *HttpVFS) Stat(name string) (fs.FileInfo, error) {
 	return file.Stat()
 }
 
-func (d *HttpVFS) Online(timeout *time.Duration) (bool, error) {
-	req, err := http.NewRequest(http.MethodHead, d.Root, nil)
+func (d *HttpVFS) GetOnlineTimeout() time.Duration {
+	if d.OnlineTimeout <= 0 {
+		return DefaultOnlineTimeout
+	}
+	return d.OnlineTimeout
+}
+
+// Online
+// Sends a HEAD to Root, reporting whether the server answered with a success before ctx is done or OnlineTimeout is over.
+// The error tells why it is not, such as the dial error of an unreachable server or a StatusError
+func (d *HttpVFS) Online(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.GetOnlineTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.Root, nil)
 	if err != nil {
 		return false, err
 	}
 
-	oldTimeout := d.HttpClient.Timeout
-	if timeout == nil {
-		d.HttpClient.Timeout = DefaultOnlineTimeout
-	} else {
-		d.HttpClient.Timeout = *timeout
+	res, err := d.Do(req)
+	if err != nil {
+		return false, err
 	}
 	defer func() {
-		d.HttpClient.Timeout = oldTimeout
+		_ = res.Body.Close()
 	}()
 
-	res, err := d.HttpClient.Do(req)
+	logFields(d.GetLogger(), "Online check", "method", http.MethodHead, "url", d.Root, "status", res.StatusCode)
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return false, statusError(res)
+	}
+
+	return true, nil
+}
+
+// Warmup
+// Sends a HEAD to Root, so the following transfer reuses an established and authorized connection
+func (d *HttpVFS) Warmup(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.Root, nil)
 	if err != nil {
-		return false, err
+		return err
+	}
+
+	res, err := d.Do(req)
+	if err != nil {
+		return err
 	}
 	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
 		_ = res.Body.Close()
 	}()
 
-	d.GetLogger().Println("Online check:", res.StatusCode)
+	logFields(d.GetLogger(), "Warmup", "method", http.MethodHead, "url", d.Root, "status", res.StatusCode)
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
-		return false, nil
+		return statusError(res)
 	}
 
-	return true, nil
+	return nil
 }
 
 type URL struct {
@@ -228,3 +552,16 @@ func (d *URL) Clone() (*URL, error) {
 	}
 	return &URL{u}, nil
 }
+
+// addQuery puts key, with value if not empty, in front of the query,
+// the existing parameters are kept byte for byte, so a signed query stays valid
+func (d *URL) addQuery(key, value string) {
+	param := url.QueryEscape(key)
+	if value != "" {
+		param += "=" + url.QueryEscape(value)
+	}
+	if d.RawQuery != "" {
+		param += "&" + d.RawQuery
+	}
+	d.RawQuery = param
+}
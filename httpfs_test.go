@@ -2,16 +2,26 @@ package vfs
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
 	crand "crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -20,15 +30,15 @@ const (
 	TestDataFolder = "testdata"
 	DufsHost       = "127.0.0.1"
 	DufsPort       = "8080"
+
+	// EnvDufsIntegration runs the tests using NewTestDufs against a real dufs at DufsAddr if set
+	EnvDufsIntegration = "DUFS_INTEGRATION"
 )
 
 //goland:noinspection HttpUrlsUsage
 var DufsAddr = "http://" + DufsHost + ":" + DufsPort
 
-type (
-	HashString string
-	FileName   string
-)
+type FileName string
 
 func Sha256(data []byte) (HashString, error) {
 	hasher := sha256.New()
@@ -83,40 +93,100 @@ func CheckDufsServer() {
 	}
 }
 
+// NewTestDufs
+// Returns the address of a dufs serving TestDataFolder, an in-memory fake mirrored to the folder,
+// or the real one at DufsAddr, waited for with CheckDufsServer, if EnvDufsIntegration is set
+func NewTestDufs(t *testing.T) string {
+	if os.Getenv(EnvDufsIntegration) != "" {
+		CheckDufsServer()
+		return DufsAddr
+	}
+	return newFakeDufsAt(t, TestDataFolder).URL
+}
+
+// removeTestData removes the files and folders under TestDataFolder once t is done
+func removeTestData(t *testing.T, names ...string) {
+	t.Cleanup(func() {
+		for _, name := range names {
+			_ = os.RemoveAll(path.Join(TestDataFolder, name))
+		}
+	})
+}
+
 func TestDufsOnline(t *testing.T) {
-	dufs, err := NewDufsVFS(DufsAddr + "1") // fake address, port 80801 should not be taken
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatal(err)
 	}
+	closed := "http://" + listener.Addr().String()
+	_ = listener.Close()
 
-	online, _ := dufs.Online(nil)
-	if online {
-		t.Fatal("dufs should be offline")
+	dufs, err := NewDufsVFS(closed)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	CheckDufsServer()
+	start := time.Now()
+	online, err := dufs.Online(context.Background())
+	if online || err == nil {
+		t.Fatal("dufs should be offline with an error, got", online, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("a closed port should be reported at once, took", elapsed)
+	}
 
-	dufs, err = NewDufsVFS(DufsAddr)
+	dufs, err = NewDufsVFS(NewTestDufs(t))
 	if err != nil {
 		t.Fatal(err)
 	}
-	online, _ = dufs.Online(nil)
-	if !online {
-		t.Fatal("dufs should be online")
+	online, err = dufs.Online(context.Background())
+	if !online || err != nil {
+		t.Fatal("dufs should be online, got", online, err)
 	}
 }
 
-func TestDufsRead(t *testing.T) {
-	CheckDufsServer()
-	dufs, err := NewDufsVFS(DufsAddr)
+func TestDufsOnlineTimeout(t *testing.T) {
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer server.Close()
+	defer close(hung)
+
+	dufs, err := NewDufsVFS(server.URL)
 	if err != nil {
 		t.Fatal(err)
 	}
+	dufs.OnlineTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	online, err := dufs.Online(context.Background())
+	if online || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("a hung server should time out, got", online, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("OnlineTimeout should bound the check, took", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	online, err = dufs.Online(ctx)
+	if online || !errors.Is(err, context.Canceled) {
+		t.Fatal("a canceled context should stop the check, got", online, err)
+	}
+}
 
+func TestDufsRead(t *testing.T) {
 	hash, filename, data, err := CreateTestData()
 	if err != nil {
 		t.Fatal(err)
 	}
+	removeTestData(t, string(filename))
+
+	dufs, err := NewDufsVFS(NewTestDufs(t))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	log.Println("Test file:", filename, ",hash:", hash)
 
@@ -183,18 +253,20 @@ func TestDufsRead(t *testing.T) {
 }
 
 func TestDufsWrite(t *testing.T) {
-	CheckDufsServer()
-	dufs, err := NewDufsVFS(DufsAddr)
+	dufs, err := NewDufsVFS(NewTestDufs(t))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	hash, filename, data, err := CreateTestData()
+	hash, local, data, err := CreateTestData()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	file, err := dufs.Open(fmt.Sprintf("test-%d.bin", time.Now().UnixNano()))
+	filename := fmt.Sprintf("test-%d.bin", time.Now().UnixNano())
+	removeTestData(t, string(local), filename)
+
+	file, err := dufs.Open(filename)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -210,7 +282,7 @@ func TestDufsWrite(t *testing.T) {
 		t.Fatal("file should be io.ReaderFrom")
 	}
 
-	buf, err := os.ReadFile(path.Join(TestDataFolder, string(filename)))
+	buf, err := os.ReadFile(path.Join(TestDataFolder, filename))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,19 +297,19 @@ func TestDufsWrite(t *testing.T) {
 }
 
 func TestDufsCopy(t *testing.T) {
-	CheckDufsServer()
-	dufs, err := NewDufsVFS(DufsAddr)
+	hash, filename, _, err := CreateTestData()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	hash, filename, _, err := CreateTestData()
+	dst := "copy-" + string(filename)
+	removeTestData(t, string(filename), dst)
+
+	dufs, err := NewDufsVFS(NewTestDufs(t))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	dst := "copy-" + string(filename)
-
 	err = dufs.Copy(dst, string(filename))
 	if err != nil {
 		t.Fatal(err)
@@ -259,11 +331,11 @@ func TestDufsCopy(t *testing.T) {
 }
 
 func TestDufsMkdir(t *testing.T) {
-	CheckDufsServer()
-	dufs, err := NewDufsVFS(DufsAddr)
+	dufs, err := NewDufsVFS(NewTestDufs(t))
 	if err != nil {
 		t.Fatal(err)
 	}
+	removeTestData(t, "test-dir-utf8-中文")
 
 	dir := fmt.Sprintf("test-dir-utf8-中文/%d/", time.Now().UnixNano())
 	err = dufs.Mkdir(dir, 0755)
@@ -280,3 +352,346 @@ func TestDufsMkdir(t *testing.T) {
 		t.Fatal(dir, "should be a directory")
 	}
 }
+
+func TestDufsWarmup(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("warm.txt", []byte("warm"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dials atomic.Int64
+	dialer := &net.Dialer{}
+	dufs.SetHttpClient(&http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dials.Add(1)
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	})
+
+	err = dufs.Warmup(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.Stat("warm.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := dials.Load(); n != 1 {
+		t.Fatal("warmed connection should be reused, got dials:", n)
+	}
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	down, err := NewDufsVFS(unavailable.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = down.Warmup(context.Background())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusServiceUnavailable {
+		t.Fatal("Warmup should fail with the StatusError of the response, got", err)
+	}
+}
+
+func TestDufsDefaultHeaders(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("headers.txt", []byte("headers"))
+
+	var locker sync.Mutex
+	seen := map[string]string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locker.Lock()
+		seen[r.Method] = r.Header.Get("Authorization")
+		locker.Unlock()
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	dufs, err := NewDufsVFS(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SetDefaultHeader("Authorization", "Bearer token")
+
+	file, err := dufs.Open("headers.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.(*DufsFile)
+
+	_, err = f.ReadFrom(strings.NewReader("replaced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt([]byte("R"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodHead} {
+		if seen[method] != "Bearer token" {
+			t.Fatal(method, "should carry the default Authorization header, got", seen[method])
+		}
+	}
+}
+
+func TestDufsMaxRedirects(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("target.txt", []byte("target"))
+
+	redirects := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if hops > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", hops-1), http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, server.URL+"/target.txt", http.StatusFound)
+	}))
+	defer redirects.Close()
+
+	dufs, err := NewDufsVFS(redirects.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SetMaxRedirects(3)
+
+	_, err = dufs.Stat("2")
+	if err != nil {
+		t.Fatal("3 redirects should be followed, got", err)
+	}
+
+	_, err = dufs.Stat("3")
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Fatal("4 redirects should fail with ErrTooManyRedirects, got", err)
+	}
+}
+
+func TestDufsReadFileContent(t *testing.T) {
+	server := newFakeDufs(t)
+	data := bytes.Repeat([]byte("read file "), 10000)
+	server.put("read-file.txt", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := dufs.ReadFile("read-file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(content) != len(data) || !bytes.Equal(content, data) {
+		t.Fatal("ReadFile should return", len(data), "bytes of content, got", len(content))
+	}
+}
+
+func TestDufsUse(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("used.txt", []byte("used"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		locker sync.Mutex
+		order  []string
+		urls   []string
+	)
+	record := func(name string) Tripperware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				locker.Lock()
+				order = append(order, name)
+				if name == "outer" {
+					urls = append(urls, req.URL.String())
+				}
+				locker.Unlock()
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	dufs.Use(record("outer"), record("inner"))
+
+	data, err := dufs.ReadFile("used.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "used" {
+		t.Fatal("requests should still reach the server, got", string(data))
+	}
+
+	locker.Lock()
+	defer locker.Unlock()
+
+	if len(urls) == 0 || !strings.HasPrefix(urls[0], server.URL+"/used.txt") {
+		t.Fatal("every request URL should be recorded, got", urls)
+	}
+	if len(order) != 2*len(urls) || order[0] != "outer" || order[1] != "inner" {
+		t.Fatal("the first tripperware should see the requests first, got", order)
+	}
+}
+
+func TestDufsSigner(t *testing.T) {
+	server := newFakeDufs(t)
+	key := []byte("secret")
+
+	sign := func(method, path, date string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(method + "\n" + path + "\n" + date))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	var locker sync.Mutex
+	signed := map[string]bool{}
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signature") != sign(r.Method, r.URL.RequestURI(), r.Header.Get("Date")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		locker.Lock()
+		signed[r.Method] = true
+		locker.Unlock()
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer gateway.Close()
+
+	dufs, err := NewDufsVFS(gateway.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.Signer = func(req *http.Request) error {
+		date := time.Now().UTC().Format(http.TimeFormat)
+		req.Header.Set("Date", date)
+		req.Header.Set("X-Signature", sign(req.Method, req.URL.RequestURI(), date))
+		return nil
+	}
+
+	file, err := dufs.Open("signed dir/signed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(File).ReadFrom(strings.NewReader("signed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dufs.Stat("signed dir/signed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locker.Lock()
+	if !signed[http.MethodPut] || !signed[http.MethodHead] {
+		t.Fatal("the PUT and the HEAD should be signed, got", signed)
+	}
+	locker.Unlock()
+
+	errUnsigned := errors.New("no key")
+	var signs atomic.Int64
+	dufs.Signer = func(*http.Request) error {
+		signs.Add(1)
+		return errUnsigned
+	}
+	dufs.RetryPolicy = &RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+	}
+	_, err = dufs.Stat("signed dir/signed.txt")
+	if !errors.Is(err, errUnsigned) {
+		t.Fatal("the error of the signer should fail the request, got", err)
+	}
+	if signs.Load() != 1 {
+		t.Fatal("a failing signer should not be retried, got", signs.Load(), "calls")
+	}
+}
+
+func TestDufsStatSys(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("sys.txt", []byte("sys"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := dufs.Stat("sys.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, ok := stat.Sys().(http.Header)
+	if !ok || !strings.HasPrefix(header.Get("Content-Type"), "text/plain") {
+		t.Fatal("Sys should return the header of the response, got", stat.Sys())
+	}
+	if stat.(*HttpFileInfo).Header().Get("Content-Type") != header.Get("Content-Type") {
+		t.Fatal("Header should return the same header as Sys")
+	}
+
+	entries, err := dufs.ReadDir(".")
+	if err != nil || len(entries) != 1 {
+		t.Fatal(entries, err)
+	}
+	info, err := entries[0].Info()
+	if err != nil || info.Sys() != nil {
+		t.Fatal("an entry of a listing should have no header, got", info.Sys(), err)
+	}
+}
+
+func TestDufsUseShared(t *testing.T) {
+	server := newFakeDufs(t)
+	server.mkdir("sub")
+	server.put("sub/shared.txt", []byte("shared"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SetHttpClient(http.DefaultClient)
+
+	sub, err := dufs.Sub("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var used atomic.Int64
+	sub.(*DufsVFS).Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			used.Add(1)
+			return next.RoundTrip(req)
+		})
+	})
+
+	if http.DefaultClient.Transport != nil || dufs.GetHttpClient() != http.DefaultClient {
+		t.Fatal("Use should not change a shared client")
+	}
+
+	_, err = dufs.ReadFile("sub/shared.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used.Load() != 0 {
+		t.Fatal("Use on a Sub should not wrap the requests of its parent")
+	}
+
+	_, err = sub.(*DufsVFS).ReadFile("shared.txt")
+	if err != nil || used.Load() == 0 {
+		t.Fatal("Use should wrap the requests of the Sub, got", used.Load(), err)
+	}
+}
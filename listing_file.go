@@ -0,0 +1,398 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lister is how a ListingFile describes files, implemented by each backend without the dufs protocol
+type lister interface {
+	// statURL describes the file at href, named name
+	statURL(href *URL, name string) (*HttpFileInfo, error)
+	// listURL describes the children of the directory at href
+	listURL(href *URL) ([]*HttpFileInfo, error)
+}
+
+// chtimer is implemented by the listers able to set the modification time of a file
+type chtimer interface {
+	chtimesURL(href *URL, name string, mtime time.Time) error
+}
+
+// ListingFile
+// A file of a server speaking plain HTTP, read with ranged GETs, written with a PUT,
+// and described by the lister of its backend
+type ListingFile struct {
+	File
+	io.Seeker
+	io.ReaderAt
+	io.WriterTo
+
+	index       int64
+	cachedState *HttpFileInfo
+	entries     []fs.DirEntry
+	listed      bool
+	locker      sync.Mutex
+
+	vfs    *HttpVFS
+	lister lister
+	Name   string
+	Href   URL
+}
+
+func newListingFile(vfs *HttpVFS, lister lister, name string, href URL) *ListingFile {
+	return &ListingFile{
+		vfs:    vfs,
+		lister: lister,
+		Name:   name,
+		Href:   href,
+	}
+}
+
+func (d *ListingFile) Stat() (fs.FileInfo, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.stat()
+}
+
+func (d *ListingFile) stat() (*HttpFileInfo, error) {
+	if d.cachedState != nil {
+		return d.cachedState, nil
+	}
+
+	href := d.Href
+	stat, err := d.lister.statURL(&href, d.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	d.cachedState = stat
+	return stat, nil
+}
+
+// Chtimes
+// Sets the modification time of the file, errors.ErrUnsupported if its backend cannot
+func (d *ListingFile) Chtimes(mtime time.Time) error {
+	chtimer, ok := d.lister.(chtimer)
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: d.Name, Err: errors.ErrUnsupported}
+	}
+
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	href := d.Href
+	err := chtimer.chtimesURL(&href, d.Name, mtime)
+	if err != nil {
+		return err
+	}
+
+	d.cachedState = nil
+	return nil
+}
+
+func (d *ListingFile) Close() error {
+	return nil
+}
+
+// ReadDir
+// Lists the directory once, then returns its entries n at a time like os.File.ReadDir
+func (d *ListingFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	if !d.listed {
+		href := d.Href
+		infos, err := d.lister.listURL(&href)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			d.entries = append(d.entries, &HttpDirEntry{info: info})
+		}
+		d.listed = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	entries := d.entries[:min(n, len(d.entries))]
+	d.entries = d.entries[len(entries):]
+	return entries, nil
+}
+
+func (d *ListingFile) Read(p []byte) (int, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	n, err := d.readAt(p, d.index)
+	d.index += int64(n)
+	return n, err
+}
+
+func (d *ListingFile) ReadAt(p []byte, off int64) (int, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.readAt(p, off)
+}
+
+// readAt reads p at off with a single Range request, returning io.EOF if the file ends before p is filled
+func (d *ListingFile) readAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	stat, err := d.stat()
+	if err != nil {
+		return 0, err
+	}
+	if stat.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: d.Name, Err: fs.ErrInvalid}
+	}
+
+	want := p
+	if stat.Size() >= 0 {
+		if off >= stat.Size() {
+			return 0, io.EOF
+		}
+		want = p[:min(int64(len(p)), stat.Size()-off)]
+	}
+
+	resp, err := d.get(fmt.Sprintf("bytes=%d-%d", off, off+int64(len(want))-1))
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return 0, io.EOF
+	} else if resp.StatusCode != http.StatusPartialContent && off > 0 {
+		return 0, errors.New("dufs: server ignored the Range header")
+	}
+
+	n, err := io.ReadFull(resp.Body, want)
+	if err == io.ErrUnexpectedEOF || (err == nil && n < len(p)) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+// get sends a GET with rangeHeader as Range if not empty, a failure status other than 416 is an error
+func (d *ListingFile) get(rangeHeader string) (*http.Response, error) {
+	href := d.Href.String()
+
+	req, err := http.NewRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := d.vfs.Do(req)
+	if err != nil {
+		logFields(d.vfs.GetLogger(), "Get file", "method", http.MethodGet, "url", href, "error", err)
+		return nil, err
+	}
+
+	logFields(d.vfs.GetLogger(), "Get file", "method", http.MethodGet, "url", href, "status", resp.StatusCode)
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return resp, nil
+	} else if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		_ = resp.Body.Close()
+		return nil, statusError(resp)
+	}
+
+	return resp, nil
+}
+
+func (d *ListingFile) Seek(offset int64, whence int) (int64, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	index := d.index
+
+	switch whence {
+	case io.SeekStart:
+		index = offset
+	case io.SeekCurrent:
+		index += offset
+	case io.SeekEnd:
+		stat, err := d.stat()
+		if err != nil {
+			return 0, err
+		}
+		index = stat.Size() + offset
+	}
+
+	if index < 0 {
+		return 0, errors.New("dufs: negative offset")
+	}
+
+	d.index = index
+	return index, nil
+}
+
+// WriteTo
+// Copies the file from the current offset with a single GET
+func (d *ListingFile) WriteTo(writer io.Writer) (int64, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	rangeHeader := ""
+	if d.index > 0 {
+		rangeHeader = fmt.Sprintf("bytes=%d-", d.index)
+	}
+
+	resp, err := d.get(rangeHeader)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return 0, nil
+	} else if resp.StatusCode != http.StatusPartialContent && d.index > 0 {
+		return 0, errors.New("dufs: server ignored the Range header")
+	}
+
+	n, err := io.Copy(writer, resp.Body)
+	d.index += n
+	return n, err
+}
+
+// ReadFrom
+// Replaces the whole file with the content of reader with a PUT, the offset is rewound to 0
+func (d *ListingFile) ReadFrom(reader io.Reader) (int64, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	href := d.Href.String()
+	contentLength := int64(0)
+
+	req, err := http.NewRequest(http.MethodPut, href, NewSumReader(reader, &contentLength))
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := d.vfs.Do(req)
+	if err != nil {
+		logFields(d.vfs.GetLogger(), "Put file", "method", http.MethodPut, "url", href, "error", err)
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	logFields(d.vfs.GetLogger(), "Put file", "method", http.MethodPut, "url", href, "status", resp.StatusCode, "bytes", contentLength)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return 0, statusError(resp)
+	}
+
+	d.cachedState = nil
+	d.index = 0
+
+	return contentLength, nil
+}
+
+func (d *ListingFile) String() string {
+	return d.Href.String()
+}
+
+// headInfo describes the file at href with a HEAD, it is a directory if the redirects end at a URL with a trailing slash
+func headInfo(vfs *HttpVFS, href *URL, name string) (*HttpFileInfo, error) {
+	link := href.String()
+
+	req, err := http.NewRequest(http.MethodHead, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := vfs.Do(req)
+	if err != nil {
+		logFields(vfs.GetLogger(), "Head file", "method", http.MethodHead, "url", link, "error", err)
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	logFields(vfs.GetLogger(), "Head file", "method", http.MethodHead, "url", link, "status", resp.StatusCode)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, statusError(resp)
+	}
+
+	mtime := parseHTTPTime(resp.Header.Get("Last-Modified"))
+	isDir := strings.HasSuffix(resp.Request.URL.Path, "/")
+
+	size := resp.ContentLength
+	if isDir {
+		size = 0
+	}
+
+	return &HttpFileInfo{
+		name:  baseName(name),
+		size:  size,
+		mode:  fs.ModePerm,
+		mtime: mtime,
+		isDir: isDir,
+
+		header: resp.Header.Clone(),
+	}, nil
+}
+
+// getHTMLListing downloads the HTML index of the directory at href and parses it with parse
+func getHTMLListing(vfs *HttpVFS, href *URL, parse func(data []byte, base *url.URL) []*HttpFileInfo) ([]*HttpFileInfo, error) {
+	dir := *href.URL
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+		dir.RawPath = ""
+	}
+	link := dir.String()
+
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := vfs.Do(req)
+	if err != nil {
+		logFields(vfs.GetLogger(), "Get listing", "method", http.MethodGet, "url", link, "error", err)
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	logFields(vfs.GetLogger(), "Get listing", "method", http.MethodGet, "url", link, "status", resp.StatusCode)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, statusError(resp)
+	} else if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fs.ErrInvalid
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(data, resp.Request.URL), nil
+}
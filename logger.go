@@ -0,0 +1,57 @@
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Logger is what requests are logged with, a *log.Logger is one
+type Logger interface {
+	Printf(format string, v ...any)
+	Println(v ...any)
+}
+
+// StructuredLogger is a Logger also taking the fields of a request, such as method, url, status and bytes, as key value pairs
+type StructuredLogger interface {
+	Logger
+	Log(msg string, args ...any)
+}
+
+// logFields logs msg with the key value pairs args, as attributes with a StructuredLogger, or appended as key=value otherwise
+func logFields(logger Logger, msg string, args ...any) {
+	if structured, ok := logger.(StructuredLogger); ok {
+		structured.Log(msg, args...)
+		return
+	}
+
+	line := []any{msg}
+	for i := 0; i+1 < len(args); i += 2 {
+		line = append(line, fmt.Sprintf("%v=%v", args[i], args[i+1]))
+	}
+	logger.Println(line...)
+}
+
+// SlogLogger
+// Adapts a *slog.Logger to StructuredLogger, each line is a record of Level, info by default
+type SlogLogger struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{Logger: logger}
+}
+
+func (d *SlogLogger) Log(msg string, args ...any) {
+	d.Logger.Log(context.Background(), d.Level, msg, args...)
+}
+
+func (d *SlogLogger) Printf(format string, v ...any) {
+	d.Logger.Log(context.Background(), d.Level, fmt.Sprintf(format, v...))
+}
+
+func (d *SlogLogger) Println(v ...any) {
+	d.Logger.Log(context.Background(), d.Level, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
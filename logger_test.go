@@ -0,0 +1,119 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (d *recordingLogger) Printf(format string, v ...any) {
+	d.lines = append(d.lines, fmt.Sprintf(format, v...))
+}
+
+func (d *recordingLogger) Println(v ...any) {
+	d.lines = append(d.lines, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func TestDufsLogger(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("logged.txt", []byte("logged"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &recordingLogger{}
+	dufs.SetLogger(recorder)
+
+	_, err = dufs.Stat("logged.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.lines) == 0 || !strings.Contains(recorder.lines[0], "logged.txt") {
+		t.Fatal("requests should be logged with the custom Logger, got", recorder.lines)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	dufs.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(buf, nil))))
+
+	_, err = dufs.Stat("logged.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "level=INFO") || !strings.Contains(buf.String(), "logged.txt") {
+		t.Fatal("requests should be logged as slog records, got", buf.String())
+	}
+
+	dufs.SetLogger((*log.Logger)(nil))
+
+	_, err = dufs.Stat("logged.txt")
+	if err != nil {
+		t.Fatal("a nil *log.Logger should discard the logs, got", err)
+	}
+}
+
+func TestDufsSlogAttributes(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("logged.txt", []byte("logged"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	dufs.SetLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+
+	file, err := dufs.Open("logged.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).WriteAt([]byte("LOG"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record struct {
+		Msg    string `json:"msg"`
+		Method string `json:"method"`
+		URL    string `json:"url"`
+		Status int    `json:"status"`
+		Bytes  int    `json:"bytes"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatal(err, buf.String())
+	}
+
+	if record.Msg != "Patch file" || record.Method != http.MethodPatch || record.Status != http.StatusNoContent || record.Bytes != 3 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if !strings.HasSuffix(record.URL, "/logged.txt") {
+		t.Fatal("record should have the url of the file, got", record.URL)
+	}
+
+	buf.Reset()
+	err = dufs.Warmup(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Bytes = 0
+	err = json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatal(err, buf.String())
+	}
+	if record.Msg != "Warmup" || record.Method != http.MethodHead || record.Status != http.StatusOK || record.URL != dufs.Root {
+		t.Fatalf("unexpected record of Warmup: %+v", record)
+	}
+}
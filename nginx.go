@@ -0,0 +1,45 @@
+package vfs
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// NginxVFS
+// A read only VFS of a server listing directories with the nginx autoindex module,
+// whose lines are the link of an entry followed by its date and size, "-" for a directory
+type NginxVFS struct {
+	*HttpVFS
+}
+
+func NewNginxVFS(root string) (*NginxVFS, error) {
+	root = strings.Trim(root, "/")
+
+	base, err := NewHttpVFS(root, "[nginx]")
+	if err != nil {
+		return nil, err
+	}
+
+	nginx := &NginxVFS{
+		HttpVFS: base,
+	}
+
+	base.OpenFunc = func(name string) (fs.File, error) {
+		href, err := joinRoot(nginx.Root, HierarchicalPathEncoder{}, name)
+		if err != nil {
+			return nil, err
+		}
+
+		return newListingFile(base, nginx, name, *href), nil
+	}
+
+	return nginx, nil
+}
+
+func (d *NginxVFS) statURL(href *URL, name string) (*HttpFileInfo, error) {
+	return headInfo(d.HttpVFS, href, name)
+}
+
+func (d *NginxVFS) listURL(href *URL) ([]*HttpFileInfo, error) {
+	return getHTMLListing(d.HttpVFS, href, parseHTMLListing)
+}
@@ -0,0 +1,127 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nginxAutoindex is the output of nginx 1.24 autoindex for /docs/
+const nginxAutoindex = `<html>
+<head><title>Index of /docs/</title></head>
+<body>
+<h1>Index of /docs/</h1><hr><pre><a href="../">../</a>
+<a href="nested/">nested/</a>                                            02-Jan-2024 03:04                   -
+<a href="a%20file.txt">a file.txt</a>                                         02-Jan-2024 03:05                  14
+<a href="b.txt">b.txt</a>                                              15-Mar-2023 22:10                1536
+</pre><hr></body>
+</html>
+`
+
+func TestNginxVFS(t *testing.T) {
+	files := map[string]string{
+		"/docs/a file.txt": "first document",
+		"/docs/b.txt":      strings.Repeat("b", 1536),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs":
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		case "/docs/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, nginxAutoindex)
+		default:
+			content, ok := files[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader(content))
+		}
+	}))
+	defer server.Close()
+
+	nginx, err := NewNginxVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := nginx.ReadDir("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listed []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed = append(listed, fmt.Sprintf("%s %t %d %s", info.Name(), info.IsDir(), info.Size(), info.ModTime().Format(time.DateTime)))
+	}
+	expected := []string{
+		"a file.txt false 14 2024-01-02 03:05:00",
+		"b.txt false 1536 2023-03-15 22:10:00",
+		"nested true 0 2024-01-02 03:04:00",
+	}
+	if strings.Join(listed, "\n") != strings.Join(expected, "\n") {
+		t.Fatal("unexpected entries:\n" + strings.Join(listed, "\n"))
+	}
+
+	stat, err := nginx.Stat("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.IsDir() {
+		t.Fatal("docs should be a directory")
+	}
+
+	stat, err = nginx.Stat("docs/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.IsDir() || stat.Size() != 1536 {
+		t.Fatal("b.txt should be a 1536 bytes file, got", stat.IsDir(), stat.Size())
+	}
+
+	data, err := nginx.ReadFile("docs/a file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != files["/docs/a file.txt"] {
+		t.Fatal("unexpected content:", string(data))
+	}
+
+	_, err = nginx.Stat("docs/missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a missing file should fail with fs.ErrNotExist, got", err)
+	}
+}
+
+func TestNginxVFSNumericZone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Tue, 02 Jan 2024 04:04:05 +0100")
+		_, _ = io.WriteString(w, "zoned")
+	}))
+	defer server.Close()
+
+	nginx, err := NewNginxVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := nginx.Stat("zoned.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.ModTime().Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatal("a Last-Modified with a numeric zone should be parsed, got", stat.ModTime())
+	}
+}
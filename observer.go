@@ -0,0 +1,34 @@
+package vfs
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Observer
+// Is told about every request once its response body is closed, such as to feed Prometheus counters and histograms.
+// status is 0 if no response was received, dur is the time until the body was closed,
+// and bytes is the size of the request body sent plus the response body read
+type Observer interface {
+	OnRequest(method string, status int, dur time.Duration, bytes int64)
+}
+
+// ObserverFunc is a function used as an Observer
+type ObserverFunc func(method string, status int, dur time.Duration, bytes int64)
+
+func (d ObserverFunc) OnRequest(method string, status int, dur time.Duration, bytes int64) {
+	d(method, status, dur, bytes)
+}
+
+// countingBody adds the bytes read from a request or response body to n, read by the transport and the caller on different goroutines
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (d *countingBody) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	d.n.Add(int64(n))
+	return n, err
+}
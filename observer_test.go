@@ -0,0 +1,91 @@
+package vfs
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type observedRequest struct {
+	method string
+	status int
+	dur    time.Duration
+	bytes  int64
+}
+
+func TestDufsObserver(t *testing.T) {
+	server := newFakeDufs(t)
+	data := bytes.Repeat([]byte("observed"), 1024)
+	server.put("observed.bin", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		locker   sync.Mutex
+		observed []observedRequest
+	)
+	dufs.Observer = ObserverFunc(func(method string, status int, dur time.Duration, bytes int64) {
+		locker.Lock()
+		defer locker.Unlock()
+		observed = append(observed, observedRequest{method, status, dur, bytes})
+	})
+
+	take := func() observedRequest {
+		t.Helper()
+
+		locker.Lock()
+		defer locker.Unlock()
+
+		if len(observed) != 1 {
+			t.Fatal("the operation should be observed once, got", observed)
+		}
+		request := observed[0]
+		observed = nil
+
+		if request.dur <= 0 {
+			t.Fatal("the duration should be measured, got", request.dur)
+		}
+		return request
+	}
+
+	_, err = dufs.Stat("observed.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request := take(); request.method != http.MethodHead || request.status != http.StatusOK || request.bytes != 0 {
+		t.Fatal("Stat should be observed as a HEAD, got", request)
+	}
+
+	content, err := dufs.ReadFile("observed.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request := take(); request.method != http.MethodGet || request.status != http.StatusOK || request.bytes != int64(len(content)) {
+		t.Fatal("ReadFile should be observed as a GET of the whole file, got", request)
+	}
+
+	file, err := dufs.Open("uploaded.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request := take(); request.method != http.MethodPut || request.status != http.StatusCreated || request.bytes != int64(len(data)) {
+		t.Fatal("ReadFrom should be observed as a PUT of the whole file, got", request)
+	}
+
+	_, err = dufs.Stat("missing.bin")
+	if err == nil {
+		t.Fatal("missing.bin should not exist")
+	}
+	if request := take(); request.status != http.StatusNotFound {
+		t.Fatal("a failure status should be observed, got", request)
+	}
+}
@@ -0,0 +1,101 @@
+package vfs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Operation struct {
+	ID        uint64
+	Method    string
+	URL       string
+	StartedAt time.Time
+}
+
+type operation struct {
+	Operation
+	cancel context.CancelFunc
+}
+
+type operations struct {
+	locker sync.Mutex
+	nextID uint64
+	active map[uint64]*operation
+}
+
+func (d *operations) add(req *http.Request, cancel context.CancelFunc) uint64 {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	if d.active == nil {
+		d.active = make(map[uint64]*operation)
+	}
+
+	d.nextID++
+	d.active[d.nextID] = &operation{
+		Operation: Operation{
+			ID:        d.nextID,
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+
+	return d.nextID
+}
+
+func (d *operations) remove(id uint64) {
+	d.locker.Lock()
+	op, ok := d.active[id]
+	delete(d.active, id)
+	d.locker.Unlock()
+
+	if ok {
+		op.cancel()
+	}
+}
+
+func (d *operations) list() []Operation {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	ops := make([]Operation, 0, len(d.active))
+	for _, op := range d.active {
+		ops = append(ops, op.Operation)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].ID < ops[j].ID
+	})
+
+	return ops
+}
+
+func (d *operations) cancel(id uint64) bool {
+	d.locker.Lock()
+	op, ok := d.active[id]
+	d.locker.Unlock()
+
+	if ok {
+		op.cancel()
+	}
+
+	return ok
+}
+
+// operationBody unregisters its operation once the response body is closed
+type operationBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (d *operationBody) Close() error {
+	err := d.ReadCloser.Close()
+	d.once.Do(d.done)
+	return err
+}
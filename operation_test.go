@@ -0,0 +1,76 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDufsCancelOperation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("slow"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("slow.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := file.(io.WriterTo).WriteTo(io.Discard)
+		done <- err
+	}()
+
+	var ops []Operation
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ops) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("download should be listed as an active operation")
+		}
+		time.Sleep(10 * time.Millisecond)
+		ops = dufs.ActiveOperations()
+	}
+
+	if ops[0].Method != http.MethodGet {
+		t.Fatal("operation method should be GET, got", ops[0].Method)
+	}
+
+	if !dufs.Cancel(ops[0].ID) {
+		t.Fatal("operation", ops[0].ID, "should be cancellable")
+	}
+
+	select {
+	case err = <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatal("download should be cancelled, got", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("download should terminate promptly after cancel")
+	}
+
+	if len(dufs.ActiveOperations()) != 0 {
+		t.Fatal("no operation should remain active")
+	}
+
+	if dufs.Cancel(ops[0].ID) {
+		t.Fatal("finished operation should not be cancellable")
+	}
+}
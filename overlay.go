@@ -0,0 +1,217 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// OverlayVFS
+// A VFS combining layers, the first has the highest priority. Open, Stat and ReadFile try the layers in order,
+// falling through to the next one only if the name does not exist in a layer, any other failure is returned as is.
+// ReadDir, and the ReadDir of an opened directory, merge the entries of the directory in all layers having it,
+// the entry of the first layer wins for a name in several layers.
+// Mkdir, Remove, Rename, Copy, WriteFile and OpenFile go to the first writable layer, which is not a ReadOnlyVFS,
+// and fail with ErrReadOnly if there is none. A name removed there is still seen if a lower layer has it.
+// Do and the other methods of VFS are those of the first layer.
+// Without layers, nothing exists, writes fail with ErrReadOnly and the other methods are those of an empty HttpVFS
+type OverlayVFS struct {
+	VFS
+
+	Layers []VFS
+}
+
+func Overlay(layers ...VFS) VFS {
+	var first VFS = &HttpVFS{}
+	if len(layers) > 0 {
+		first = layers[0]
+	}
+	return &OverlayVFS{
+		VFS:    first,
+		Layers: layers,
+	}
+}
+
+// resolve calls fn with the layers in order until it does not fail with fs.ErrNotExist
+func (d *OverlayVFS) resolve(op, name string, fn func(layer VFS) error) error {
+	for _, layer := range d.Layers {
+		err := fn(layer)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (d *OverlayVFS) Stat(name string) (fs.FileInfo, error) {
+	var stat fs.FileInfo
+	err := d.resolve("stat", name, func(layer VFS) (err error) {
+		stat, err = layer.Stat(name)
+		return err
+	})
+	return stat, err
+}
+
+func (d *OverlayVFS) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := d.resolve("read", name, func(layer VFS) (err error) {
+		data, err = layer.ReadFile(name)
+		return err
+	})
+	return data, err
+}
+
+// Open
+// Stats name in each layer to open it in the first one having it, as a VFS may open a missing file lazily
+func (d *OverlayVFS) Open(name string) (fs.File, error) {
+	var file fs.File
+	err := d.resolve("open", name, func(layer VFS) error {
+		stat, err := layer.Stat(name)
+		if err != nil {
+			return err
+		}
+
+		file, err = layer.Open(name)
+		if err != nil {
+			return err
+		}
+
+		if stat.IsDir() {
+			file = &overlayDir{File: file, name: name, vfs: d}
+		}
+		return nil
+	})
+	return file, err
+}
+
+func (d *OverlayVFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := map[string]bool{}
+	found := false
+
+	for _, layer := range d.Layers {
+		layerEntries, err := layer.ReadDir(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		found = true
+		for _, entry := range layerEntries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries, nil
+}
+
+// writable is the first layer which is not read-only, the one changed by the writes
+func (d *OverlayVFS) writable(op, name string) (mutator, error) {
+	for _, layer := range d.Layers {
+		if _, ok := layer.(*ReadOnlyVFS); ok {
+			continue
+		}
+		if vfs, ok := layer.(mutator); ok {
+			return vfs, nil
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+}
+
+func (d *OverlayVFS) Mkdir(name string, perm fs.FileMode) error {
+	vfs, err := d.writable("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return vfs.Mkdir(name, perm)
+}
+
+func (d *OverlayVFS) Remove(name string) error {
+	vfs, err := d.writable("remove", name)
+	if err != nil {
+		return err
+	}
+	return vfs.Remove(name)
+}
+
+func (d *OverlayVFS) Rename(oldname, newname string) error {
+	vfs, err := d.writable("rename", oldname)
+	if err != nil {
+		return err
+	}
+	return vfs.Rename(oldname, newname)
+}
+
+func (d *OverlayVFS) Copy(dst, src string) error {
+	vfs, err := d.writable("copy", dst)
+	if err != nil {
+		return err
+	}
+	return vfs.Copy(dst, src)
+}
+
+func (d *OverlayVFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	vfs, err := d.writable("write", name)
+	if err != nil {
+		return err
+	}
+	writer, ok := vfs.(interface {
+		WriteFile(name string, data []byte, perm fs.FileMode) error
+	})
+	if !ok {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.ErrUnsupported}
+	}
+	return writer.WriteFile(name, data, perm)
+}
+
+// OpenFile
+// Opens name in the writable layer whatever the flag is, such as to create or replace it, use Open to read through the layers
+func (d *OverlayVFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	vfs, err := d.writable("open", name)
+	if err != nil {
+		return nil, err
+	}
+	opener, ok := vfs.(interface {
+		OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	})
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+	return opener.OpenFile(name, flag, perm)
+}
+
+// overlayDir is a directory opened in a layer, listing the merged entries of the directory in all layers
+type overlayDir struct {
+	fs.File
+
+	name    string
+	vfs     *OverlayVFS
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.vfs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+
+	return readDirPage(&d.entries, n, nil)
+}
@@ -0,0 +1,155 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	upperServer := newFakeDufs(t)
+	upperServer.put("shared.txt", []byte("upper"))
+	upperServer.put("dir/upper.txt", []byte("upper only"))
+
+	lowerServer := newFakeDufs(t)
+	lowerServer.put("shared.txt", []byte("lower"))
+	lowerServer.put("lower.txt", []byte("lower only"))
+	lowerServer.put("dir/lower.txt", []byte("lower only"))
+
+	upper, err := NewDufsVFS(upperServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, err := NewDufsVFS(lowerServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overlay := Overlay(upper, lower)
+
+	for name, want := range map[string]string{
+		"shared.txt":    "upper",
+		"lower.txt":     "lower only",
+		"dir/upper.txt": "upper only",
+		"dir/lower.txt": "lower only",
+	} {
+		data, err := overlay.ReadFile(name)
+		if err != nil || string(data) != want {
+			t.Fatal("ReadFile", name, "should read", want, "got", string(data), err)
+		}
+
+		file, err := overlay.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err = io.ReadAll(file)
+		_ = file.Close()
+		if err != nil || string(data) != want {
+			t.Fatal("Open", name, "should read", want, "got", string(data), err)
+		}
+	}
+
+	stat, err := overlay.Stat("lower.txt")
+	if err != nil || stat.Size() != int64(len("lower only")) {
+		t.Fatal("Stat should fall through to the lower layer, got", stat, err)
+	}
+
+	_, err = overlay.ReadFile("missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a name in no layer should not exist, got", err)
+	}
+	_, err = overlay.Open("missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Open should not open a name in no layer, got", err)
+	}
+
+	assertNames := func(entries []fs.DirEntry, want ...string) {
+		t.Helper()
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if len(names) != len(want) {
+			t.Fatal("entries should be", want, "got", names)
+		}
+		for i := range names {
+			if names[i] != want[i] {
+				t.Fatal("entries should be", want, "got", names)
+			}
+		}
+	}
+
+	entries, err := overlay.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNames(entries, "dir", "lower.txt", "shared.txt")
+	for _, entry := range entries {
+		if entry.Name() != "shared.txt" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() != int64(len("upper")) {
+			t.Fatal("the shadowed entry should be the one of the upper layer, got", info, err)
+		}
+	}
+
+	dir, err := overlay.Open("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := dir.(fs.ReadDirFile).ReadDir(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := dir.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNames(append(first, rest...), "lower.txt", "upper.txt")
+	_, err = dir.(fs.ReadDirFile).ReadDir(1)
+	if err != io.EOF {
+		t.Fatal("ReadDir past the entries should be io.EOF, got", err)
+	}
+	_ = dir.Close()
+
+	err = overlay.(*OverlayVFS).WriteFile("written.txt", []byte("written"), fs.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := upperServer.get("written.txt"); !ok || string(data) != "written" {
+		t.Fatal("writes should go to the upper layer, got", string(data))
+	}
+
+	readOnlyUpper := Overlay(ReadOnly(upper), lower).(*OverlayVFS)
+	err = readOnlyUpper.Mkdir("made", fs.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lowerServer.dirs["made"] || upperServer.dirs["made"] {
+		t.Fatal("writes should skip a read-only layer")
+	}
+
+	err = Overlay(ReadOnly(upper)).(*OverlayVFS).Remove("shared.txt")
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatal("writes without a writable layer should fail with ErrReadOnly, got", err)
+	}
+
+	empty := Overlay()
+	_, err = empty.Open(".")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("nothing should exist without layers, got", err)
+	}
+	_, err = empty.ReadDir(".")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("nothing should be listed without layers, got", err)
+	}
+	err = empty.(*OverlayVFS).WriteFile("written.txt", nil, fs.ModePerm)
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatal("writes without layers should fail with ErrReadOnly, got", err)
+	}
+	if len(empty.ActiveOperations()) != 0 {
+		t.Fatal("an empty overlay should have no operations")
+	}
+}
@@ -0,0 +1,44 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+)
+
+// passthroughFile passes the reads of a wrapped file through to File, failing with fs.ErrInvalid for those it lacks
+type passthroughFile struct {
+	fs.File
+
+	name string
+}
+
+func (d *passthroughFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	dir, ok := d.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrInvalid}
+	}
+	return dir.ReadDir(n)
+}
+
+func (d *passthroughFile) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := d.File.(io.Seeker)
+	if !ok {
+		return 0, &fs.PathError{Op: "seek", Path: d.name, Err: fs.ErrInvalid}
+	}
+	return seeker.Seek(offset, whence)
+}
+
+func (d *passthroughFile) ReadAt(p []byte, off int64) (int, error) {
+	readerAt, ok := d.File.(io.ReaderAt)
+	if !ok {
+		return 0, &fs.PathError{Op: "readat", Path: d.name, Err: fs.ErrInvalid}
+	}
+	return readerAt.ReadAt(p, off)
+}
+
+func (d *passthroughFile) WriteTo(writer io.Writer) (int64, error) {
+	if writerTo, ok := d.File.(io.WriterTo); ok {
+		return writerTo.WriteTo(writer)
+	}
+	return io.Copy(writer, struct{ io.Reader }{d.File})
+}
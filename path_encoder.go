@@ -0,0 +1,52 @@
+package vfs
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PathEncoder maps a file name to the escaped URL path appended to the root, and a listed entry name back to a file name
+type PathEncoder interface {
+	Encode(name string) string
+	Decode(seg string) string
+}
+
+func splitName(name string) []string {
+	var segments []string
+	for _, s := range strings.Split(name, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// HierarchicalPathEncoder maps each segment of a name to a URL path segment, "a/b/c" is requested as "/a/b/c"
+type HierarchicalPathEncoder struct{}
+
+func (d HierarchicalPathEncoder) Encode(name string) string {
+	segments := splitName(name)
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (d HierarchicalPathEncoder) Decode(seg string) string {
+	return seg
+}
+
+// FlatPathEncoder maps a whole name to a single URL path segment, "a/b/c" is requested as "/a%2Fb%2Fc"
+type FlatPathEncoder struct{}
+
+func (d FlatPathEncoder) Encode(name string) string {
+	return url.PathEscape(strings.Join(splitName(name), "/"))
+}
+
+func (d FlatPathEncoder) Decode(seg string) string {
+	name, err := url.PathUnescape(seg)
+	if err != nil {
+		return seg
+	}
+	return name
+}
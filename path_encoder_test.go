@@ -0,0 +1,154 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDufsFlatPathEncoder(t *testing.T) {
+	var locker sync.Mutex
+	keys := map[string][]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locker.Lock()
+		defer locker.Unlock()
+
+		key := strings.TrimPrefix(r.URL.EscapedPath(), "/")
+		if strings.Contains(key, "/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case key == "":
+			index := DufsJSONIndex{}
+			for k, v := range keys {
+				index.Paths = append(index.Paths, DufsJSONFile{Name: k, Size: int64(len(v))})
+			}
+			data, _ := json.Marshal(index)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-cache")
+			_, _ = w.Write(data)
+		case r.Method == http.MethodPut:
+			keys[key], _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case keys[key] != nil:
+			w.Header().Set("Content-Disposition", "inline")
+			http.ServeContent(w, r, key, time.Now(), bytes.NewReader(keys[key]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.PathEncoder = FlatPathEncoder{}
+
+	file, err := dufs.Open("a/b/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = file.(io.ReaderFrom).ReadFrom(strings.NewReader("flat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys["a%2Fb%2Fc.txt"] == nil {
+		t.Fatal("a/b/c.txt should be stored as a single key, got", keys)
+	}
+
+	stat, err := dufs.Stat("a/b/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Size() != 4 {
+		t.Fatal("file size should be 4, got", stat.Size())
+	}
+
+	entries, err := dufs.ReadDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "a/b/c.txt" {
+		t.Fatal("listing should decode to a/b/c.txt, got", entries)
+	}
+}
+
+func TestDufsSpecialNames(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL + "/root dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{
+		"with space.txt",
+		"hash#tag.txt",
+		"plus+sign.txt",
+		"100%.txt",
+		"what?.txt",
+		"中文/名字 #1.txt",
+	} {
+		data := []byte("content of " + name)
+
+		file, err := dufs.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = file.(*DufsFile).ReadFrom(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		stored, ok := server.get("root dir/" + name)
+		if !ok || !bytes.Equal(stored, data) {
+			t.Fatal(name, "should be stored under its own name, got", server.files)
+		}
+
+		href, err := dufs.appendToRoot(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if href.Fragment != "" || href.RawQuery != "" {
+			t.Fatal(name, "should be escaped in the path, got", href.String())
+		}
+
+		stat, err := dufs.Stat(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if stat.Size() != int64(len(data)) || stat.IsDir() {
+			t.Fatalf("%s should be a file of %d bytes, got %d", name, len(data), stat.Size())
+		}
+
+		content, err := dufs.ReadFile(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if !bytes.Equal(content, data) {
+			t.Fatal(name, "read data mismatch")
+		}
+	}
+
+	entries, err := dufs.ReadDir("中文")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "名字 #1.txt" {
+		t.Fatal("listed names should be unescaped, got", entries)
+	}
+}
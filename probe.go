@@ -0,0 +1,80 @@
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const DefaultProbeSize = 1024 * 1024
+
+// Probe
+// Measures the latency with a HEAD to Root, and the bandwidth in bytes per second by downloading
+// the first ProbeSize bytes of ProbePath, or the root index if ProbePath is empty
+func (d *DufsVFS) Probe(ctx context.Context) (time.Duration, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.Root, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Now()
+	resp, err := d.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	latency := time.Since(start)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return latency, 0, statusError(resp)
+	}
+
+	href, err := d.appendToRoot(d.ProbePath)
+	if err != nil {
+		return latency, 0, err
+	}
+
+	size := d.ProbeSize
+	if size <= 0 {
+		size = DefaultProbeSize
+	}
+
+	if d.ProbePath == "" {
+		href, err = NewDufsFile(d, "", *href).jsonize()
+		if err != nil {
+			return latency, 0, err
+		}
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, href.String(), nil)
+	if err != nil {
+		return latency, 0, err
+	}
+	if d.ProbePath != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", size-1))
+	}
+
+	resp, err = d.Do(req)
+	if err != nil {
+		return latency, 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return latency, 0, statusError(resp)
+	}
+
+	start = time.Now()
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return latency, 0, err
+	}
+	elapsed := max(time.Since(start), time.Microsecond)
+
+	return latency, int64(float64(n) / elapsed.Seconds()), nil
+}
@@ -0,0 +1,73 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// shapedTransport delays every response by Latency and limits reading bodies to Bandwidth bytes per second
+type shapedTransport struct {
+	Latency   time.Duration
+	Bandwidth int64
+}
+
+func (d *shapedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(d.Latency)
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &shapedBody{ReadCloser: resp.Body, bandwidth: d.Bandwidth}
+	return resp, nil
+}
+
+type shapedBody struct {
+	io.ReadCloser
+	bandwidth int64
+}
+
+func (d *shapedBody) Read(p []byte) (int, error) {
+	if len(p) > 4096 {
+		p = p[:4096]
+	}
+	n, err := d.ReadCloser.Read(p)
+	time.Sleep(time.Duration(n) * time.Second / time.Duration(d.bandwidth))
+	return n, err
+}
+
+func TestDufsProbe(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("probe.bin", bytes.Repeat([]byte{1}, 512*1024))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SetHttpClient(&http.Client{
+		Transport: &shapedTransport{
+			Latency:   50 * time.Millisecond,
+			Bandwidth: 1024 * 1024,
+		},
+	})
+	dufs.ProbePath = "probe.bin"
+	dufs.ProbeSize = 256 * 1024
+
+	latency, bandwidth, err := dufs.Probe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("latency:", latency, "bandwidth:", bandwidth)
+
+	if latency < 50*time.Millisecond || latency > 500*time.Millisecond {
+		t.Fatal("latency should be about 50ms, got", latency)
+	}
+
+	if bandwidth < 512*1024 || bandwidth > 1024*1024*11/10 {
+		t.Fatal("bandwidth should be about 1MiB/s, got", bandwidth)
+	}
+}
@@ -0,0 +1,66 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+)
+
+var ErrRangeNotSatisfiable = fmt.Errorf("dufs: range not satisfiable: %w", fs.ErrInvalid)
+
+// RangeReader
+// Returns the body of a single Range request for length bytes at off, streamed without a copy,
+// and without using or moving the offset of the file, so it is safe for concurrent use. The caller must close it.
+// A range beyond the cached size, or answered with a 416, fails with ErrRangeNotSatisfiable
+func (d *DufsFile) RangeReader(off, length int64) (reader io.ReadCloser, err error) {
+	err = d.checkAccess("read", false)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := d.vfs.startSpan(d.getContext(), "RangeReader", d.Name)
+	defer func() {
+		span.End(err)
+	}()
+
+	stat, err := d.cachedStat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, &fs.PathError{Op: "range", Path: d.Name, Err: fs.ErrInvalid}
+	}
+	if off < 0 || length < 0 || off+length > stat.Size() {
+		return nil, &fs.PathError{Op: "range", Path: d.Name, Err: ErrRangeNotSatisfiable}
+	}
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	d.setIfRange(header)
+
+	resp, err := d.get(ctx, header)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusRequestedRangeNotSatisfiable {
+			statusErr.Err = ErrRangeNotSatisfiable
+		}
+		return nil, err
+	}
+
+	err = d.checkUnchanged(resp)
+	if err == nil && resp.StatusCode != http.StatusPartialContent && (off > 0 || length < stat.Size()) {
+		err = errors.New("dufs: server ignored the Range header")
+	}
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,109 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestDufsRangeReader(t *testing.T) {
+	server := newFakeDufs(t)
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server.put("media.bin", content)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("media.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := file.(*DufsFile)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := int64(0); i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			off, length := i*1000, int64(500+i)
+			reader, err := media.RangeReader(off, length)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer func() {
+				_ = reader.Close()
+			}()
+
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				errs <- err
+			} else if !bytes.Equal(data, content[off:off+length]) {
+				errs <- fmt.Errorf("range %d+%d read %d unexpected bytes", off, length, len(data))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if media.Tell() != 0 {
+		t.Fatal("the offset of the file should not move, got", media.Tell())
+	}
+
+	for _, r := range [][2]int64{{9990, 11}, {-1, 10}, {0, -1}} {
+		_, err = media.RangeReader(r[0], r[1])
+		if !errors.Is(err, ErrRangeNotSatisfiable) {
+			t.Fatal("range", r, "should fail with ErrRangeNotSatisfiable, got", err)
+		}
+	}
+
+	reader, err := media.RangeReader(9990, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil || string(data) != "0123456789" {
+		t.Fatal("the last 10 bytes should be read, got", string(data), err)
+	}
+}
+
+func TestDufsRangeReaderNotSatisfiable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "inline")
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Length", "100")
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("shrunk.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = file.(*DufsFile).RangeReader(50, 10)
+	var statusErr *StatusError
+	if !errors.Is(err, ErrRangeNotSatisfiable) || !errors.As(err, &statusErr) || statusErr.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatal("a 416 should fail with ErrRangeNotSatisfiable, got", err)
+	}
+}
@@ -0,0 +1,61 @@
+package vfs
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// tokenBucket is shared by the transfers of a direction, it starts empty and holds at most a second of tokens
+type tokenBucket struct {
+	locker sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// wait takes n tokens, then sleeps until the bucket is no longer in debt
+func (d *tokenBucket) wait(ctx context.Context, n int, rate int64) error {
+	d.locker.Lock()
+	now := time.Now()
+	if !d.last.IsZero() {
+		d.tokens = min(d.tokens+now.Sub(d.last).Seconds()*float64(rate), float64(rate))
+	}
+	d.last = now
+	d.tokens -= float64(n)
+	debt := -d.tokens
+	d.locker.Unlock()
+
+	if debt <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(debt / float64(rate) * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedBody waits for the bucket after each Read
+type rateLimitedBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	bucket *tokenBucket
+	rate   int64
+}
+
+func (d *rateLimitedBody) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	if n > 0 {
+		werr := d.bucket.wait(d.ctx, n, d.rate)
+		if werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
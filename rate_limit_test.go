@@ -0,0 +1,47 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDufsRateLimit(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.RateLimit = 100 << 10
+
+	data := bytes.Repeat([]byte("x"), 150<<10)
+	minimum := time.Second
+
+	file, err := dufs.Open("limited.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = file.(*DufsFile).ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < minimum {
+		t.Fatal("uploading", len(data), "bytes at", dufs.RateLimit, "B/s should take at least", minimum, "took", elapsed)
+	}
+
+	start = time.Now()
+	n, err := file.(*DufsFile).WriteTo(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Fatal("should download", len(data), "bytes, got", n)
+	}
+	if elapsed := time.Since(start); elapsed < minimum {
+		t.Fatal("downloading", len(data), "bytes at", dufs.RateLimit, "B/s should take at least", minimum, "took", elapsed)
+	}
+}
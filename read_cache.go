@@ -0,0 +1,56 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// readCached reads p at off from the content cache, revalidated with If-None-Match before each read
+func (d *DufsFile) readCached(ctx context.Context, p []byte, off int64) (int, error) {
+	content, err := d.cachedContent(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if off >= int64(len(content)) {
+		return 0, nil
+	}
+
+	return copy(p, content[off:]), nil
+}
+
+// cachedContent returns the content cache if the server answers 304 Not Modified, otherwise downloads it again
+func (d *DufsFile) cachedContent(ctx context.Context) ([]byte, error) {
+	d.contentLocker.Lock()
+	defer d.contentLocker.Unlock()
+
+	header := http.Header{}
+	if d.content != nil && d.contentETag != "" {
+		header.Set("If-None-Match", d.contentETag)
+	}
+
+	resp, err := d.get(ctx, header)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return d.content, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, d.contextErr(err)
+	}
+
+	d.content = buf.Bytes()
+	d.contentETag = resp.Header.Get("ETag")
+
+	return d.content, nil
+}
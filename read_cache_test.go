@@ -0,0 +1,96 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDufsReadCacheSize(t *testing.T) {
+	var (
+		locker        sync.Mutex
+		content       = []byte("cached content")
+		etag          = `"v1"`
+		downloads     int
+		revalidations int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locker.Lock()
+		defer locker.Unlock()
+
+		w.Header().Set("Content-Disposition", "attachment")
+		w.Header().Set("ETag", etag)
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == etag {
+			revalidations++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		downloads++
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.ReadCacheSize = 1024
+
+	file, err := dufs.Open("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufsFile := file.(*DufsFile)
+
+	for i := 0; i < 3; i++ {
+		p := make([]byte, 6)
+		_, err = dufsFile.ReadFullAt(p, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != "conten" {
+			t.Fatal("cached read mismatch, got", string(p))
+		}
+	}
+
+	if downloads != 1 || revalidations != 2 {
+		t.Fatal("repeated reads should download once and revalidate twice, got", downloads, revalidations)
+	}
+
+	locker.Lock()
+	content = []byte("changed content")
+	etag = `"v2"`
+	locker.Unlock()
+
+	_, err = dufsFile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufsFile.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(dufsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatal("a changed ETag should invalidate the cache, got", string(data))
+	}
+	if downloads != 2 {
+		t.Fatal("a changed ETag should download again, got", downloads, "downloads")
+	}
+}
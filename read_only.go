@@ -0,0 +1,82 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+)
+
+var ErrReadOnly = fmt.Errorf("dufs: read-only: %w", fs.ErrPermission)
+
+// ReadOnlyVFS
+// A VFS which cannot change the server: Mkdir, Remove, Rename, Copy and the writes of its files fail with ErrReadOnly,
+// and Do only sends GET, HEAD, OPTIONS and PROPFIND requests. Open, ReadDir, ReadFile and Stat are passed through
+type ReadOnlyVFS struct {
+	VFS
+}
+
+func ReadOnly(vfs VFS) VFS {
+	return &ReadOnlyVFS{
+		VFS: vfs,
+	}
+}
+
+func (d *ReadOnlyVFS) Open(name string) (fs.File, error) {
+	file, err := d.VFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{
+		passthroughFile: passthroughFile{File: file, name: name},
+	}, nil
+}
+
+func (d *ReadOnlyVFS) Do(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return d.VFS.Do(req)
+	}
+	return nil, &fs.PathError{Op: req.Method, Path: req.URL.Path, Err: ErrReadOnly}
+}
+
+func (d *ReadOnlyVFS) Mkdir(name string, _ fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: ErrReadOnly}
+}
+
+func (d *ReadOnlyVFS) MkdirAll(name string, _ fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: ErrReadOnly}
+}
+
+func (d *ReadOnlyVFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: ErrReadOnly}
+}
+
+func (d *ReadOnlyVFS) Rename(oldname, _ string) error {
+	return &fs.PathError{Op: "rename", Path: oldname, Err: ErrReadOnly}
+}
+
+func (d *ReadOnlyVFS) Copy(dst, _ string) error {
+	return &fs.PathError{Op: "copy", Path: dst, Err: ErrReadOnly}
+}
+
+// readOnlyFile passes the reads through to File and fails the writes with ErrReadOnly
+type readOnlyFile struct {
+	passthroughFile
+}
+
+func (d *readOnlyFile) ReadFrom(io.Reader) (int64, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.name, Err: ErrReadOnly}
+}
+
+func (d *readOnlyFile) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.name, Err: ErrReadOnly}
+}
+
+func (d *readOnlyFile) WriteAt([]byte, int64) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.name, Err: ErrReadOnly}
+}
+
+func (d *readOnlyFile) Truncate(int64) error {
+	return &fs.PathError{Op: "truncate", Path: d.name, Err: ErrReadOnly}
+}
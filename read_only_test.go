@@ -0,0 +1,92 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("kept.txt", []byte("kept"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := ReadOnly(dufs)
+
+	data, err := readOnly.ReadFile("kept.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "kept" {
+		t.Fatal("reads should pass through, got", string(data))
+	}
+
+	stat, err := readOnly.Stat("kept.txt")
+	if err != nil || stat.Size() != 4 {
+		t.Fatal("Stat should pass through, got", stat, err)
+	}
+
+	file, err := readOnly.Open("kept.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2)
+	_, err = file.(io.ReaderAt).ReadAt(buf, 2)
+	if err != nil || string(buf) != "pt" {
+		t.Fatal("ReadAt should pass through, got", string(buf), err)
+	}
+
+	_, err = file.(File).ReadFrom(strings.NewReader("replaced"))
+	if !errors.Is(err, ErrReadOnly) || !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("ReadFrom should fail with ErrReadOnly, got", err)
+	}
+	_, err = file.(io.Writer).Write([]byte("replaced"))
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatal("Write should fail with ErrReadOnly, got", err)
+	}
+	_, err = file.(io.WriterAt).WriteAt([]byte("replaced"), 0)
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatal("WriteAt should fail with ErrReadOnly, got", err)
+	}
+
+	mutator := readOnly.(interface {
+		Mkdir(name string, perm fs.FileMode) error
+		Remove(name string) error
+		Rename(oldname, newname string) error
+		Copy(dst, src string) error
+	})
+	for _, err = range []error{
+		mutator.Mkdir("dir", fs.ModePerm),
+		mutator.Remove("kept.txt"),
+		mutator.Rename("kept.txt", "renamed.txt"),
+		mutator.Copy("copied.txt", "kept.txt"),
+	} {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Fatal("mutations should fail with ErrReadOnly, got", err)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL+"/kept.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readOnly.Do(req)
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatal("Do should not send a DELETE, got", err)
+	}
+
+	if data, _ := server.get("kept.txt"); string(data) != "kept" {
+		t.Fatal("the server should be untouched, got", string(data))
+	}
+	if len(server.files) != 1 || server.dirs["dir"] {
+		t.Fatal("no file or directory should be created")
+	}
+}
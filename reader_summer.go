@@ -1,6 +1,9 @@
 package vfs
 
-import "io"
+import (
+	"hash"
+	"io"
+)
 
 type ReaderSummer struct {
 	Reader io.Reader
@@ -16,3 +19,34 @@ func (d *ReaderSummer) Read(p []byte) (int, error) {
 func NewSumReader(reader io.Reader, sum *int64) io.Reader {
 	return &ReaderSummer{Reader: reader, Sum: sum}
 }
+
+type WriterSummer struct {
+	Writer io.Writer
+	Sum    *int64
+}
+
+func (d *WriterSummer) Write(p []byte) (int, error) {
+	n, err := d.Writer.Write(p)
+	*d.Sum += int64(n)
+	return n, err
+}
+
+func NewSumWriter(writer io.Writer, sum *int64) io.Writer {
+	return &WriterSummer{Writer: writer, Sum: sum}
+}
+
+// ReaderHasher writes everything read from Reader to Hash
+type ReaderHasher struct {
+	Reader io.Reader
+	Hash   hash.Hash
+}
+
+func (d *ReaderHasher) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	_, _ = d.Hash.Write(p[:n])
+	return n, err
+}
+
+func NewHashReader(reader io.Reader, hash hash.Hash) io.Reader {
+	return &ReaderHasher{Reader: reader, Hash: hash}
+}
@@ -0,0 +1,150 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxRetries int
+	// BaseDelay is doubled after every attempt
+	BaseDelay time.Duration
+	// Retryable reports whether a response or error is transient, DefaultRetryable is used if nil
+	Retryable func(resp *http.Response, err error) bool
+	// RetryWrites lets the ranged PATCHes of WriteAt be resent, writing the same bytes to the same range again.
+	// They are not retried by default, as a retry races with the other writes to the file. Appends are never retried
+	RetryWrites bool
+}
+
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// delay is the Retry-After of resp if any, the backoff of attempt otherwise
+func (d *RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if after, ok := retryAfter(resp); ok {
+			return after
+		}
+	}
+	return d.BaseDelay << attempt
+}
+
+// retryAfter parses the Retry-After header, as seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func (d *RetryPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if !isIdempotent(req) || !isRewindable(req) {
+		return false
+	}
+	var signErr *signError
+	if errors.As(err, &signErr) {
+		return false
+	}
+	if d.Retryable == nil {
+		return DefaultRetryable(resp, err)
+	}
+	return d.Retryable(resp, err)
+}
+
+// isIdempotent follows net/http, so a write like a PATCH is only retried if it opts in with a header:
+// a request with an Idempotency-Key or X-Idempotency-Key header is idempotent,
+// a nil header value marks the request without sending the header
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+func isRewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindOnRetry lets req be resent by seeking body back to its current offset
+func rewindOnRetry(req *http.Request, body io.Reader, newBody func() io.Reader) {
+	seeker, ok := body.(io.Seeker)
+	if !ok {
+		return
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		_, err := seeker.Seek(start, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(newBody()), nil
+	}
+}
+
+func (d *HttpVFS) doWithRetry(req *http.Request) (*http.Response, error) {
+	policy := d.RetryPolicy
+
+	for attempt := 0; ; attempt++ {
+		resp, err := d.do(req)
+		if policy == nil || attempt >= policy.MaxRetries || !policy.retryable(req, resp, err) {
+			return resp, err
+		}
+
+		delay := policy.delay(attempt, resp)
+
+		reason := any(err)
+		if resp != nil {
+			reason = resp.Status
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		logFields(d.GetLogger(), "Retry", "method", req.Method, "url", req.URL.String(), "delay", delay, "attempt", attempt+1, "error", reason)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errInjectedFault = errors.New("injected fault")
+
+// faultTransport fails the first Failures requests with Method after consuming their bodies
+type faultTransport struct {
+	Transport http.RoundTripper
+	Method    string
+	Failures  int
+	// Truncate cuts the body of every request of Method to Truncate bytes if positive
+	Truncate int64
+
+	locker   sync.Mutex
+	attempts int
+}
+
+func (d *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d.locker.Lock()
+	fail := req.Method == d.Method && d.attempts < d.Failures
+	if req.Method == d.Method {
+		d.attempts++
+	}
+	d.locker.Unlock()
+
+	if fail {
+		if req.Body != nil {
+			_, _ = io.Copy(io.Discard, req.Body)
+			_ = req.Body.Close()
+		}
+		return nil, errInjectedFault
+	}
+
+	if d.Truncate > 0 && req.Method == d.Method && req.ContentLength > d.Truncate {
+		body, err := io.ReadAll(io.LimitReader(req.Body, d.Truncate))
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = d.Truncate
+	}
+
+	transport := d.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
+func TestDufsRetryRewindsBody(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("retry.bin", bytes.Repeat([]byte{'-'}, 64))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &faultTransport{Method: http.MethodPatch, Failures: 1}
+	dufs.SetHttpClient(&http.Client{Transport: transport})
+	dufs.RetryPolicy = &RetryPolicy{
+		MaxRetries:  2,
+		BaseDelay:   time.Millisecond,
+		RetryWrites: true,
+	}
+
+	file, err := dufs.Open("retry.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patch := bytes.Repeat([]byte{'+'}, 64)
+	_, err = file.(*DufsFile).WriteAt(patch, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transport.attempts != 2 {
+		t.Fatal("PATCH should be sent twice, got", transport.attempts)
+	}
+
+	data, _ := server.get("retry.bin")
+	if !bytes.Equal(data, patch) {
+		t.Fatal("retried PATCH content mismatch:", string(data))
+	}
+
+	transport = &faultTransport{Method: http.MethodPut, Failures: 1}
+	dufs.SetHttpClient(&http.Client{Transport: transport})
+
+	_, err = file.(io.ReaderFrom).ReadFrom(io.MultiReader(bytes.NewReader(patch)))
+	if !errors.Is(err, errInjectedFault) {
+		t.Fatal("one-shot body should not be retried, got", err)
+	}
+
+	transport = &faultTransport{Method: http.MethodPut, Failures: 1}
+	dufs.SetHttpClient(&http.Client{Transport: transport})
+
+	_, err = file.(io.ReaderFrom).ReadFrom(bytes.NewReader([]byte("rewound")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transport.attempts != 2 {
+		t.Fatal("PUT should be sent twice, got", transport.attempts)
+	}
+
+	data, _ = server.get("retry.bin")
+	if string(data) != "rewound" {
+		t.Fatal("retried PUT content mismatch:", string(data))
+	}
+}
+
+func TestDufsRetryTransientStatus(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("flaky.txt", []byte("flaky"))
+
+	var failures atomic.Int64
+	statuses := []int{http.StatusServiceUnavailable, http.StatusBadGateway}
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failed := failures.Add(1); failed <= int64(len(statuses)) {
+			w.WriteHeader(statuses[failed-1])
+			return
+		}
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+
+	dufs, err := NewDufsVFS(flaky.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.RetryPolicy = &RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+	}
+
+	stat, err := dufs.Stat("flaky.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 5 || failures.Load() != 3 {
+		t.Fatal("Stat should succeed on the third attempt, got", stat.Size(), "after", failures.Load(), "attempts")
+	}
+
+	failures.Store(0)
+	statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+
+	_, err = dufs.Stat("flaky.txt")
+	if err == nil || failures.Load() != 3 {
+		t.Fatal("Stat should give up after 2 retries, got", err, "after", failures.Load(), "attempts")
+	}
+
+	failures.Store(0)
+	statuses = []int{http.StatusServiceUnavailable}
+
+	req, err := http.NewRequest(http.MethodPatch, flaky.URL+"/flaky.txt", strings.NewReader("!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Update-Range", "append")
+
+	resp, err := dufs.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || failures.Load() != 1 {
+		t.Fatal("an appending PATCH should not be retried, got", resp.Status, "after", failures.Load(), "attempts")
+	}
+}
+
+func TestDufsRetryAfter(t *testing.T) {
+	var attempts atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Disposition", "inline")
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.RetryPolicy = &RetryPolicy{
+		MaxRetries: 1,
+		BaseDelay:  time.Millisecond,
+	}
+
+	started := time.Now()
+	_, err = dufs.Stat("limited.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Fatal("retry should wait for Retry-After, waited", elapsed)
+	}
+}
+
+func TestDufsRetryWrites(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("unavailable.bin", bytes.Repeat([]byte{'-'}, 8))
+
+	var patches atomic.Int64
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			patches.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer unavailable.Close()
+
+	dufs, err := NewDufsVFS(unavailable.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.RetryPolicy = &RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+	}
+
+	file, err := dufs.Open("unavailable.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = file.(*DufsFile).WriteAt([]byte("+"), 0)
+	if !errors.Is(err, &StatusError{Code: http.StatusServiceUnavailable}) {
+		t.Fatal("WriteAt should fail with the 503, got", err)
+	}
+	if patches.Load() != 1 {
+		t.Fatal("a PATCH should not be retried without RetryWrites, got", patches.Load(), "attempts")
+	}
+
+	patches.Store(0)
+	dufs.RetryPolicy.RetryWrites = true
+
+	_, err = file.(*DufsFile).WriteAt([]byte("+"), 0)
+	if err == nil || patches.Load() != 3 {
+		t.Fatal("a PATCH should be retried with RetryWrites, got", patches.Load(), "attempts", err)
+	}
+}
@@ -0,0 +1,76 @@
+package vfs
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// Search
+// Lists the entries under dir matching query with the search of dufs, their names are relative to dir.
+// Fails with fs.ErrPermission if the server does not allow searching dir, checked beforehand with EnforceCapabilities
+func (d *DufsVFS) Search(dir, query string) ([]fs.DirEntry, error) {
+	err := d.checkDirCapability("search", dir, strings.Trim(dir, "/"), canSearch)
+	if err != nil {
+		return nil, err
+	}
+
+	href, err := d.appendToRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	file := NewDufsFile(d, dir, *href)
+
+	link, err := file.jsonize()
+	if err != nil {
+		return nil, err
+	}
+	link.addQuery("q", query)
+
+	req, err := http.NewRequest(http.MethodGet, link.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	logFields(d.GetLogger(), "Search", "method", http.MethodGet, "url", link.String(), "status", resp.StatusCode)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, statusError(resp)
+	}
+
+	if !file.determineIsDir(resp) {
+		return nil, ErrNoJSONListing
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var index DufsJSONIndex
+	err = json.Unmarshal(data, &index)
+	if err != nil {
+		return nil, err
+	}
+
+	if !index.AllowSearch {
+		return nil, &fs.PathError{Op: "search", Path: dir, Err: ErrNotPermitted}
+	}
+
+	var entries []fs.DirEntry
+	for _, path := range index.Paths {
+		entries = append(entries, file.dirEntry(path))
+	}
+
+	return entries, nil
+}
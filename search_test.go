@@ -0,0 +1,51 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestDufsSearch(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("docs/report 2024.txt", []byte("a"))
+	server.put("docs/old/report&summary.txt", []byte("b"))
+	server.put("docs/notes.txt", []byte("c"))
+	server.put("other/report.txt", []byte("d"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for query, expected := range map[string][]string{
+		"report":    {"old/report&summary.txt", "report 2024.txt"},
+		"report&s":  {"old/report&summary.txt"},
+		"t 2024":    {"report 2024.txt"},
+		"no match?": nil,
+	} {
+		entries, err := dufs.Search("docs", query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			t.Fatal("search of", query, "should find", expected, "got", names)
+		}
+		if expected == nil && entries != nil {
+			t.Fatal("search without results should return nil, got", entries)
+		}
+	}
+
+	server.DenySearch = true
+
+	_, err = dufs.Search("docs", "report")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("search should fail with fs.ErrPermission when disallowed, got", err)
+	}
+}
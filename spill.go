@@ -0,0 +1,128 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+)
+
+const DefaultSpillMemorySize = 8 << 20
+
+func (d *DufsVFS) GetSpillMemorySize() int64 {
+	if d.SpillMemorySize <= 0 {
+		return DefaultSpillMemorySize
+	}
+	return d.SpillMemorySize
+}
+
+// AcceptsRanges
+// Reports whether reads at an offset are sent as Range requests, which is the case unless the server
+// sent Accept-Ranges: none, or answered a Range with the whole file. Otherwise the whole file is downloaded once,
+// and Read, ReadAt and Seek are served from that copy, removed on Close.
+// A server not sending Accept-Ranges: bytes is still sent Range requests, as many honor them without advertising it
+// and the first one ignored is detected
+func (d *DufsFile) AcceptsRanges() (bool, error) {
+	_, err := d.cachedStat(d.getContext())
+	if err != nil {
+		return false, err
+	}
+	return d.acceptsRanges(), nil
+}
+
+func (d *DufsFile) acceptsRanges() bool {
+	d.cachedStateLocker.Lock()
+	defer d.cachedStateLocker.Unlock()
+	return !d.rangesUnsupported
+}
+
+// readSpilled reads at most len(p) bytes at off from the copy of the file, downloaded first if missing or outdated
+func (d *DufsFile) readSpilled(ctx context.Context, p []byte, off int64) (int, error) {
+	spill, err := d.spilled(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := spill.ReadAt(p, off)
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// spilled returns the copy of the file, downloaded again once the cached state is refreshed with another validator
+func (d *DufsFile) spilled(ctx context.Context) (io.ReaderAt, error) {
+	stat, err := d.cachedStat(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.cachedStateLocker.Lock()
+	validator := d.validator
+	d.cachedStateLocker.Unlock()
+
+	d.spillLocker.Lock()
+	defer d.spillLocker.Unlock()
+
+	if d.spill != nil && (d.spillStat == stat || (validator != "" && d.spillValidator == validator)) {
+		return d.spill, nil
+	}
+	d.dropSpillLocked()
+
+	resp, err := d.get(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if validator != "" && validatorOf(resp) != validator {
+		return nil, ErrFileChanged
+	}
+
+	logFields(d.FS.GetLogger(), "Spill file", "url", d.Href.String(), "size", stat.Size())
+
+	if stat.Size() >= 0 && stat.Size() <= d.vfs.GetSpillMemorySize() {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, d.contextErr(err)
+		}
+		d.spill = bytes.NewReader(data)
+	} else {
+		file, err := os.CreateTemp("", "dufs-spill-*")
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(file, resp.Body)
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+			return nil, d.contextErr(err)
+		}
+		d.spill = file
+		d.spillFile = file
+	}
+
+	d.spillStat = stat
+	d.spillValidator = validator
+	return d.spill, nil
+}
+
+// dropSpill releases the copy of the file, removing its temporary file
+func (d *DufsFile) dropSpill() {
+	d.spillLocker.Lock()
+	defer d.spillLocker.Unlock()
+	d.dropSpillLocked()
+}
+
+func (d *DufsFile) dropSpillLocked() {
+	if d.spillFile != nil {
+		_ = d.spillFile.Close()
+		_ = os.Remove(d.spillFile.Name())
+	}
+	d.spill = nil
+	d.spillFile = nil
+	d.spillStat = nil
+	d.spillValidator = ""
+}
@@ -0,0 +1,181 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// newRangelessServer serves content with the whole body whatever the Range, with Accept-Ranges set to acceptRanges if not empty
+func newRangelessServer(t *testing.T, content []byte, acceptRanges string) (*httptest.Server, *atomic.Int64, *atomic.Int64) {
+	var gets, ranges atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "inline")
+		w.Header().Set("ETag", `"rangeless"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if acceptRanges != "" {
+			w.Header().Set("Accept-Ranges", acceptRanges)
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		gets.Add(1)
+		if r.Header.Get("Range") != "" {
+			ranges.Add(1)
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	return server, &gets, &ranges
+}
+
+func TestDufsRangeIgnored(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server, gets, _ := newRangelessServer(t, content, "")
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("rangeless.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rangeless := file.(*DufsFile)
+
+	acceptsRanges, err := rangeless.AcceptsRanges()
+	if err != nil || !acceptsRanges {
+		t.Fatal("ranges should be tried without Accept-Ranges, got", acceptsRanges, err)
+	}
+
+	_, err = rangeless.Seek(1234, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 10)
+	_, err = io.ReadFull(rangeless, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "4567890123" {
+		t.Fatal("Read after Seek should read at the offset, got", string(p))
+	}
+
+	acceptsRanges, err = rangeless.AcceptsRanges()
+	if err != nil || acceptsRanges {
+		t.Fatal("an ignored Range should be remembered, got", acceptsRanges, err)
+	}
+
+	for _, off := range []int64{0, 5, 9990, 4321} {
+		n, err := rangeless.ReadAt(p, off)
+		if err != nil || n != 10 || !bytes.Equal(p, content[off:off+10]) {
+			t.Fatal("ReadAt", off, "should read the copy, got", string(p[:n]), err)
+		}
+	}
+	n, err := rangeless.ReadAt(p, 9995)
+	if n != 5 || err != io.EOF {
+		t.Fatal("ReadAt at the end should read 5 bytes then io.EOF, got", n, err)
+	}
+
+	// the ignored Range, then the download of the copy
+	if gets.Load() != 2 {
+		t.Fatal("the file should be downloaded once more, got", gets.Load(), "GETs")
+	}
+
+	err = rangeless.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDufsAcceptRangesNone(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), 200000)
+	server, gets, ranges := newRangelessServer(t, content, "none")
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SpillMemorySize = 1 << 20
+
+	file, err := dufs.Open("large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rangeless := file.(*DufsFile)
+
+	acceptsRanges, err := rangeless.AcceptsRanges()
+	if err != nil || acceptsRanges {
+		t.Fatal("Accept-Ranges: none should be recorded by Stat, got", acceptsRanges, err)
+	}
+
+	p := make([]byte, 10)
+	for _, off := range []int64{int64(len(content)) - 10, 3, 1 << 20} {
+		_, err = rangeless.ReadFullAt(p, off)
+		if err != nil || !bytes.Equal(p, content[off:off+10]) {
+			t.Fatal("ReadFullAt", off, "should read the copy, got", string(p), err)
+		}
+	}
+
+	if ranges.Load() != 0 || gets.Load() != 1 {
+		t.Fatal("the file should be downloaded once without Range, got", gets.Load(), "GETs with", ranges.Load(), "Range")
+	}
+
+	spill := rangeless.spillFile
+	if spill == nil {
+		t.Fatal("a file over SpillMemorySize should be copied to a temporary file")
+	}
+	err = rangeless.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(spill.Name()); !os.IsNotExist(err) {
+		t.Fatal("Close should remove the temporary file, got", err)
+	}
+}
+
+func TestDufsSpillUnknownSize(t *testing.T) {
+	content := []byte("small file of unknown size")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "inline")
+		w.Header().Set("Accept-Ranges", "none")
+		if r.Method == http.MethodHead {
+			return
+		}
+		// flushing before the body sends it chunked, without Content-Length
+		w.(http.Flusher).Flush()
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("unknown.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rangeless := file.(*DufsFile)
+
+	p := make([]byte, 7)
+	_, err = rangeless.ReadFullAt(p, 6)
+	if err != nil || !bytes.Equal(p, content[6:13]) {
+		t.Fatal("ReadFullAt should read the copy, got", string(p), err)
+	}
+
+	if rangeless.spillFile == nil {
+		t.Fatal("a file of unknown size should be copied to a temporary file, not to memory")
+	}
+	err = rangeless.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,62 @@
+package vfs
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// StatusError
+// The error of a response with a failure status, get it with errors.As for the code.
+// errors.Is still matches the fs error of the status, such as fs.ErrNotExist for a 404
+type StatusError struct {
+	Code   int
+	Status string
+	Method string
+	URL    string
+
+	// Err is the error matched by errors.Is, nil if the status has no meaning of its own
+	Err error
+}
+
+func (d *StatusError) Error() string {
+	return "dufs: " + d.Method + " " + d.URL + ": " + d.Status
+}
+
+func (d *StatusError) Unwrap() error {
+	return d.Err
+}
+
+// Is
+// Matches a StatusError with the same Code, such as errors.Is(err, &StatusError{Code: http.StatusTooManyRequests})
+func (d *StatusError) Is(target error) bool {
+	statusErr, ok := target.(*StatusError)
+	return ok && statusErr.Code == d.Code
+}
+
+// statusError is the error of a response with a failure status, matching the fs error of the statuses meaning the same for any request
+func statusError(resp *http.Response) *StatusError {
+	var err error
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		err = ErrUnauthorized
+	case http.StatusForbidden:
+		err = fs.ErrPermission
+	case http.StatusNotFound:
+		err = fs.ErrNotExist
+	}
+	return newStatusError(resp, err)
+}
+
+// newStatusError is the error of a response with a failure status matching err, such as fs.ErrExist for a 412 to a COPY
+func newStatusError(resp *http.Response, err error) *StatusError {
+	statusErr := &StatusError{
+		Code:   resp.StatusCode,
+		Status: resp.Status,
+		Err:    err,
+	}
+	if resp.Request != nil {
+		statusErr.Method = resp.Request.Method
+		statusErr.URL = resp.Request.URL.String()
+	}
+	return statusErr
+}
@@ -0,0 +1,103 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDufsStatusError(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("exists.txt", []byte("exists"))
+	server.mkdir("dir")
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(err error, code int, method string, is error) {
+		t.Helper()
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("expected a StatusError, got %T %v", err, err)
+		}
+		if statusErr.Code != code || statusErr.Method != method || !strings.HasPrefix(statusErr.URL, server.URL) {
+			t.Fatalf("expected %s %d, got %+v", method, code, statusErr)
+		}
+		if is != nil && !errors.Is(err, is) {
+			t.Fatalf("%v should match %v", err, is)
+		}
+		if !errors.Is(err, &StatusError{Code: code}) {
+			t.Fatalf("%v should match a StatusError with code %d", err, code)
+		}
+	}
+
+	_, err = dufs.Stat("missing.txt")
+	expect(err, http.StatusNotFound, http.MethodHead, fs.ErrNotExist)
+
+	err = dufs.Remove("missing.txt")
+	expect(err, http.StatusNotFound, http.MethodDelete, fs.ErrNotExist)
+
+	err = dufs.Mkdir("dir", fs.ModePerm)
+	expect(err, http.StatusMethodNotAllowed, "MKCOL", fs.ErrExist)
+
+	err = dufs.CopyWithOpts("exists.txt", "dir", CopyOpts{Overwrite: OverwriteFail})
+	expect(err, http.StatusPreconditionFailed, "COPY", fs.ErrExist)
+
+	err = dufs.Rename("missing.txt", "renamed.txt")
+	expect(err, http.StatusNotFound, "MOVE", fs.ErrNotExist)
+
+	file, err := dufs.Open("missing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).WriteAt([]byte("patch"), 1)
+	expect(err, http.StatusNotFound, http.MethodPatch, fs.ErrNotExist)
+}
+
+func TestStatusErrorCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forbidden.txt":
+			w.WriteHeader(http.StatusForbidden)
+		case "/unauthorized.txt":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusInsufficientStorage)
+		}
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dufs.Stat("forbidden.txt")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("403 should match fs.ErrPermission, got", err)
+	}
+
+	_, err = dufs.Stat("unauthorized.txt")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatal("401 should match ErrUnauthorized, got", err)
+	}
+
+	file, err := dufs.Open("full.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).ReadFrom(strings.NewReader("full"))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusInsufficientStorage || statusErr.Method != http.MethodPut {
+		t.Fatal("ReadFrom should fail with the status of the PUT, got", err)
+	}
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		t.Fatal("507 should not match an fs error")
+	}
+}
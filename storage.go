@@ -0,0 +1,90 @@
+package vfs
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const storageStatsPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:quota-available-bytes/><D:quota-used-bytes/></D:prop></D:propfind>`
+
+type davQuotaMultistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				Available string `xml:"quota-available-bytes"`
+				Used      string `xml:"quota-used-bytes"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// StorageStats
+// Reads the RFC 4331 quota properties of the root with a PROPFIND,
+// fails with errors.ErrUnsupported if the server does not report them
+func (d *DufsVFS) StorageStats() (total, used, free int64, err error) {
+	root, err := d.appendToRoot("")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	req, err := http.NewRequest("PROPFIND", root.String(), strings.NewReader(storageStatsPropfind))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return 0, 0, 0, errors.ErrUnsupported
+	default:
+		return 0, 0, 0, statusError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var multistatus davQuotaMultistatus
+	err = xml.Unmarshal(data, &multistatus)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+
+			used, err = strconv.ParseInt(strings.TrimSpace(propstat.Prop.Used), 10, 64)
+			if err != nil {
+				continue
+			}
+			free, err = strconv.ParseInt(strings.TrimSpace(propstat.Prop.Available), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			return used + free, used, free, nil
+		}
+	}
+
+	return 0, 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,58 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDufsStorageStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != "PROPFIND" || r.Header.Get("Depth") != "0" || !strings.Contains(string(body), "quota-used-bytes") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response>
+    <D:href>/</D:href>
+    <D:propstat>
+      <D:prop><D:quota-available-bytes>750</D:quota-available-bytes><D:quota-used-bytes>250</D:quota-used-bytes></D:prop>
+      <D:status>HTTP/1.1 200 OK</D:status>
+    </D:propstat>
+  </D:response>
+</D:multistatus>`))
+	}))
+	defer server.Close()
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total, used, free, err := dufs.StorageStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1000 || used != 250 || free != 750 {
+		t.Fatal("stats should be 1000, 250 and 750, got", total, used, free)
+	}
+
+	fake := newFakeDufs(t)
+
+	dufs, err = NewDufsVFS(fake.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = dufs.StorageStats()
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatal("a server without quota should fail with errors.ErrUnsupported, got", err)
+	}
+}
@@ -0,0 +1,72 @@
+package vfs
+
+import (
+	"io/fs"
+)
+
+// Sub
+// Returns a DufsVFS rooted at dir, with the same settings and credentials, implementing fs.SubFS.
+// dir is resolved like any other name, so it cannot climb out of the root with ".."
+func (d *DufsVFS) Sub(dir string) (fs.FS, error) {
+	err := d.checkName("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	href, err := d.appendToRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := NewDufsVFS(href.String())
+	if err != nil {
+		return nil, err
+	}
+
+	d.copySettings(sub)
+
+	return sub, nil
+}
+
+// copySettings copies everything but the root, the OpenFunc and the state of the requests in flight to to
+func (d *HttpVFS) copySettings(to *HttpVFS) {
+	to.StrictFS = d.StrictFS
+	to.Logger = d.Logger
+	to.HttpClient = d.HttpClient
+	to.OnlineTimeout = d.OnlineTimeout
+	to.PrefetchWidth = d.PrefetchWidth
+	to.PrefetchDepth = d.PrefetchDepth
+	to.RetryPolicy = d.RetryPolicy
+	to.Headers = d.Headers.Clone()
+	to.Signer = d.Signer
+	to.RateLimit = d.RateLimit
+	to.Observer = d.Observer
+	to.Tracer = d.Tracer
+	to.username = d.username
+	to.password = d.password
+}
+
+// copySettings copies the options of d to to, the cached capabilities are not shared
+func (d *DufsVFS) copySettings(to *DufsVFS) {
+	d.HttpVFS.copySettings(to.HttpVFS)
+
+	to.PathEncoder = d.PathEncoder
+	to.MTimeUnit = d.MTimeUnit
+	to.ProbePath = d.ProbePath
+	to.ProbeSize = d.ProbeSize
+	to.HTMLListing = d.HTMLListing
+	to.SmallFileThreshold = d.SmallFileThreshold
+	to.PartSize = d.PartSize
+	to.EnforceCapabilities = d.EnforceCapabilities
+	to.CapabilityTTL = d.CapabilityTTL
+	to.CopyConcurrency = d.CopyConcurrency
+	to.VerifyWrites = d.VerifyWrites
+	to.StatTTL = d.StatTTL
+	to.KeepTrailingEmptyLine = d.KeepTrailingEmptyLine
+	to.TarDirectories = d.TarDirectories
+	to.EnableGzip = d.EnableGzip
+	to.ReadCacheSize = d.ReadCacheSize
+	to.UploadProgress = d.UploadProgress
+	to.AppendFlushSize = d.AppendFlushSize
+	to.SpillMemorySize = d.SpillMemorySize
+}
@@ -0,0 +1,72 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestDufsSub(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("a/b/c.txt", []byte("scoped"))
+	server.put("a/outside.txt", []byte("outside"))
+
+	dufs, err := NewDufsVFSWithAuth(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.StatTTL = 42
+
+	subFS, err := fs.Sub(dufs, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, ok := subFS.(*DufsVFS)
+	if !ok {
+		t.Fatalf("fs.Sub should use DufsVFS.Sub, got %T", subFS)
+	}
+	if sub.StatTTL != 42 || sub.username != "user" {
+		t.Fatal("Sub should keep the settings and credentials")
+	}
+
+	data, err := fs.ReadFile(sub, "c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "scoped" {
+		t.Fatal("c.txt should resolve to a/b/c.txt, got", string(data))
+	}
+
+	entries, err := sub.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "c.txt" {
+		t.Fatal("the root of Sub should be a/b, got", entries)
+	}
+
+	file, err := sub.Open("new.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(File).ReadFrom(strings.NewReader("written"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := server.get("a/b/new.txt"); string(data) != "written" {
+		t.Fatal("writes should land under a/b, got", string(data))
+	}
+
+	for _, name := range []string{"../outside.txt", "c/../../outside.txt"} {
+		_, err = sub.Stat(name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Fatal(name, "should not escape the subtree, got", err)
+		}
+	}
+
+	_, err = dufs.Sub("a/../..")
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("Sub should reject a traversal, got", err)
+	}
+}
@@ -0,0 +1,74 @@
+package vfs
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// TarDir
+// Writes the tree under dir as a tar stream, assembled on the client side with WalkDir and a download per file
+func (d *HttpVFS) TarDir(w io.Writer, dir string) error {
+	writer := tar.NewWriter(w)
+
+	err := d.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, dir), "/")
+		if rel == "" {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		// the mtime is truncated, the tar writer would round it to the second
+		header := &tar.Header{
+			Name:    rel,
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime().Truncate(time.Second),
+		}
+
+		if entry.IsDir() {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			return writer.WriteHeader(header)
+		}
+
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+
+		err = writer.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := d.Open(name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		_, err = io.Copy(writer, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Tar
+// Same as TarDir, with the arguments in the order of the other DufsVFS methods
+func (d *DufsVFS) Tar(dir string, w io.Writer) error {
+	return d.TarDir(w, dir)
+}
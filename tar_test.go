@@ -0,0 +1,169 @@
+package vfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func untar(t *testing.T, data []byte) map[string]HashString {
+	members := map[string]HashString{}
+	reader := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hash, err := Sha256(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			hash = ""
+		}
+		members[header.Name] = hash
+	}
+	return members
+}
+
+func TestDufsTarDir(t *testing.T) {
+	server := newFakeDufs(t)
+
+	tree := map[string][]byte{
+		"t/a.txt":              []byte("a"),
+		"t/sub/b.bin":          bytes.Repeat([]byte{0, 1, 2, 3}, 100000),
+		"t/sub/deeper/c.txt":   []byte("c"),
+		"outside/ignored.text": []byte("ignored"),
+	}
+	for name, data := range tree {
+		server.put(name, data)
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = dufs.TarDir(buf, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := untar(t, buf.Bytes())
+
+	if len(members) != 5 {
+		t.Fatal("tar should contain 3 files and 2 directories, got", members)
+	}
+
+	for _, dir := range []string{"sub/", "sub/deeper/"} {
+		if hash, ok := members[dir]; !ok || hash != "" {
+			t.Fatal(dir, "should be a directory member")
+		}
+	}
+
+	for name, data := range tree {
+		if name == "outside/ignored.text" {
+			continue
+		}
+		hash, err := Sha256(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if members[name[len("t/"):]] != hash {
+			t.Fatal("hash mismatch for", name)
+		}
+	}
+
+	dufs.TarDirectories = true
+
+	file, err := dufs.Open("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	_, err = io.Copy(buf, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(untar(t, buf.Bytes())) != len(members) {
+		t.Fatal("copying a directory should produce the same tar stream")
+	}
+}
+
+func TestDufsTar(t *testing.T) {
+	server := newFakeDufs(t)
+
+	tree := map[string][]byte{
+		"photos/2024/a.jpg":   bytes.Repeat([]byte{0xff, 0xd8}, 30000),
+		"photos/2024/b.jpg":   []byte("b"),
+		"photos/readme.txt":   []byte("holidays"),
+		"photos/empty/.keep":  nil,
+		"videos/not-tar.webm": []byte("ignored"),
+	}
+	for name, data := range tree {
+		server.put(name, data)
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = dufs.Tar("photos", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	reader := tar.NewReader(buf)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+
+		if header.Typeflag == tar.TypeDir {
+			stat, err := dufs.Stat("photos/" + header.Name)
+			if err != nil || !stat.IsDir() {
+				t.Fatal(header.Name, "should be a directory of the tree, got", err)
+			}
+			continue
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source, ok := tree["photos/"+header.Name]
+		if !ok || !bytes.Equal(content, source) || header.Size != int64(len(source)) {
+			t.Fatal(header.Name, "should have the", len(source), "bytes of the tree, got", len(content))
+		}
+		if !header.ModTime.Equal(server.mtimes["photos/"+header.Name].Truncate(time.Second)) {
+			t.Fatal(header.Name, "should have the mtime of the server, got", header.ModTime)
+		}
+	}
+
+	expected := "2024/,2024/a.jpg,2024/b.jpg,empty/,empty/.keep,readme.txt"
+	if strings.Join(names, ",") != expected {
+		t.Fatal("the tar should contain", expected, "got", names)
+	}
+}
@@ -0,0 +1,55 @@
+package vfs
+
+import (
+	"context"
+	"io"
+)
+
+// Span
+// A unit of work started by a Tracer, an OpenTelemetry span can be adapted with a few lines
+type Span interface {
+	SetAttribute(key string, value any)
+	End(err error)
+}
+
+// Tracer
+// Starts the span of a file operation, the returned context carries it to the requests of that operation
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, any) {}
+
+func (noopSpan) End(error) {}
+
+type spanKey struct{}
+
+// startSpan starts a span named name for the file at path, a no-op span if there is no Tracer
+func (d *HttpVFS) startSpan(ctx context.Context, name, path string) (context.Context, Span) {
+	if d.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+
+	ctx, span := d.Tracer.Start(ctx, name)
+	span.SetAttribute("vfs.path", path)
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// readSpanError is the error a span of a read ends with, io.EOF is the end of the file and not a failure
+func readSpanError(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// spanFromContext returns the span started by startSpan for ctx, a no-op span if there is none
+func spanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
@@ -0,0 +1,179 @@
+package vfs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordedSpan struct {
+	name       string
+	attributes map[string]any
+	ended      bool
+	err        error
+}
+
+func (d *recordedSpan) SetAttribute(key string, value any) {
+	d.attributes[key] = value
+}
+
+func (d *recordedSpan) End(err error) {
+	d.ended = true
+	d.err = err
+}
+
+type recordingTracer struct {
+	locker sync.Mutex
+	spans  []*recordedSpan
+}
+
+func (d *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	span := &recordedSpan{name: name, attributes: map[string]any{}}
+	d.spans = append(d.spans, span)
+	return ctx, span
+}
+
+// take returns the spans recorded since the last call
+func (d *recordingTracer) take() []*recordedSpan {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	spans := d.spans
+	d.spans = nil
+	return spans
+}
+
+func TestDufsTracer(t *testing.T) {
+	server := newFakeDufs(t)
+	server.put("traced.txt", []byte("traced"))
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := &recordingTracer{}
+	dufs.Tracer = tracer
+
+	expect := func(name string, status int) {
+		t.Helper()
+
+		spans := tracer.take()
+		if len(spans) != 1 {
+			t.Fatalf("%s should start one span, got %d", name, len(spans))
+		}
+
+		span := spans[0]
+		if span.name != name || !span.ended {
+			t.Fatalf("expected an ended span named %s, got %s (ended %v)", name, span.name, span.ended)
+		}
+		if status != 0 && span.attributes["http.status_code"] != status {
+			t.Fatalf("%s should record status %d, got %v", name, status, span.attributes)
+		}
+		if status != 0 && (span.attributes["http.method"] == nil || !strings.HasPrefix(span.attributes["http.url"].(string), server.URL)) {
+			t.Fatalf("%s should record the method and url, got %v", name, span.attributes)
+		}
+	}
+
+	file, err := dufs.Open("traced.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Open", 0)
+
+	dufsFile := file.(*DufsFile)
+
+	_, err = dufsFile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Stat", 200)
+
+	_, err = dufsFile.ReadAt(make([]byte, 3), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("ReadAt", 206)
+
+	_, err = dufsFile.Read(make([]byte, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Read", 206)
+
+	_, err = dufsFile.ReadAt(make([]byte, 16), 3)
+	if err != io.EOF {
+		t.Fatal("ReadAt past the end should be io.EOF, got", err)
+	}
+	if spans := tracer.take(); len(spans) != 1 || spans[0].err != nil {
+		t.Fatal("a read reaching io.EOF should end its span without an error, got", spans)
+	}
+
+	_, err = dufsFile.WriteAt([]byte("T"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("WriteAt", 204)
+
+	_, err = dufsFile.ReadFrom(strings.NewReader("replaced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("ReadFrom", 201)
+
+	_ = file.Close()
+
+	_, err = dufs.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer.take()
+
+	dir, err := dufs.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Open", 0)
+
+	_, err = dir.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("ReadDir", 200)
+
+	err = dufs.Mkdir("traced", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Mkdir", 201)
+
+	err = dufs.Copy("copied.txt", "traced.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Copy", 201)
+
+	err = dufs.Rename("copied.txt", "renamed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Rename", 201)
+
+	err = dufs.Remove("renamed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Remove", 204)
+
+	_, _, err = dufs.Exists("traced.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Exists", 200)
+}
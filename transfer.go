@@ -0,0 +1,334 @@
+package vfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+var ErrChecksumMismatch = errors.New("dufs: checksum mismatch")
+
+// ProgressFunc is called with the bytes transferred so far and the total, -1 if unknown
+type ProgressFunc func(done, total int64)
+
+// DefaultProgressStep is the bytes written between two calls of the ProgressFunc of WriteToWithProgress
+const DefaultProgressStep = 32 << 10
+
+// progressWriter reports the bytes written to it, at most once per step bytes if step is set, flush reports the rest
+type progressWriter struct {
+	done     int64
+	total    int64
+	step     int64
+	reported int64
+	progress ProgressFunc
+}
+
+func (d *progressWriter) Write(p []byte) (int, error) {
+	d.done += int64(len(p))
+	if d.progress != nil && d.done-d.reported >= d.step {
+		d.reported = d.done
+		d.progress(d.done, d.total)
+	}
+	return len(p), nil
+}
+
+func (d *progressWriter) flush() {
+	if d.progress != nil && d.done != d.reported {
+		d.reported = d.done
+		d.progress(d.done, d.total)
+	}
+}
+
+// pipe streams src into upload, and a copy of it into tees such as a hash, without buffering it.
+// io.Copy reads src with its WriteTo if it has one, such as a DufsFile
+func pipe(src io.Reader, upload func(reader io.Reader) error, tees ...io.Writer) error {
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_, err := io.Copy(io.MultiWriter(append([]io.Writer{writer}, tees...)...), src)
+		_ = writer.CloseWithError(err)
+	}()
+
+	err := upload(reader)
+	_ = reader.CloseWithError(err)
+	<-done
+
+	return err
+}
+
+// Transfer
+// Streams src to dst through the client, for when a server side Copy is not possible,
+// then downloads dst again to compare its sha256 with what was sent
+func (d *DufsVFS) Transfer(dst, src string, progress ProgressFunc) error {
+	srcFile, stat, err := d.OpenWithStat(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	if stat.IsDir() {
+		return &fs.PathError{Op: "transfer", Path: src, Err: fs.ErrInvalid}
+	}
+
+	dstHref, err := d.appendToRoot(dst)
+	if err != nil {
+		return err
+	}
+	dstFile := NewDufsFile(d, dst, *dstHref)
+
+	hasher := sha256.New()
+	err = pipe(srcFile, func(reader io.Reader) error {
+		_, err := dstFile.ReadFromSized(reader, stat.Size())
+		return err
+	}, hasher, &progressWriter{total: stat.Size(), progress: progress})
+	if err != nil {
+		return err
+	}
+
+	dstHash, err := sha256Sum(dstFile)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(dstHash, hasher.Sum(nil)) {
+		return &fs.PathError{Op: "transfer", Path: dst, Err: ErrChecksumMismatch}
+	}
+
+	return nil
+}
+
+type TransferOpts struct {
+	// RemoveSource removes srcPath from the source once all of it is transferred
+	RemoveSource bool
+	// Progress is called like a ProgressFunc for each file, with its name in the source
+	Progress func(name string, done, total int64)
+}
+
+// Transfer
+// Copies the file or the tree at srcPath of src to dstPath of dst through the client,
+// such as between two servers, where neither a COPY nor a MOVE can go
+func Transfer(dst VFS, dstPath string, src VFS, srcPath string) error {
+	return TransferWithOpts(dst, dstPath, src, srcPath, TransferOpts{})
+}
+
+// MoveAcrossServers
+// Same as Transfer, then removes srcPath from src
+func MoveAcrossServers(dst VFS, dstPath string, src VFS, srcPath string) error {
+	return TransferWithOpts(dst, dstPath, src, srcPath, TransferOpts{RemoveSource: true})
+}
+
+// TransferWithOpts
+// Walks srcPath of src, creating its directories in dst and streaming its files one at a time from their WriteTo
+// into the ReadFrom of a file of dst. Each file is then checked to have the size and the sha256 of what was sent,
+// with the Hash of dst if it has one, by reading it back otherwise, failing with ErrChecksumMismatch.
+// It stops at the first failure, in which case nothing is removed
+func TransferWithOpts(dst VFS, dstPath string, src VFS, srcPath string, opts TransferOpts) error {
+	err := fs.WalkDir(src, srcPath, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := dstPath
+		if name != srcPath {
+			rel := name
+			if srcPath != "." {
+				rel = strings.TrimPrefix(name, srcPath+"/")
+			}
+			target = path.Join(dstPath, rel)
+		}
+
+		if entry.IsDir() {
+			return transferDir(dst, target, name == srcPath)
+		}
+		return transferFile(dst, target, src, name, opts.Progress)
+	})
+	if err != nil || !opts.RemoveSource {
+		return err
+	}
+
+	remover, ok := src.(interface {
+		Remove(name string) error
+	})
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: srcPath, Err: errors.ErrUnsupported}
+	}
+	return remover.Remove(srcPath)
+}
+
+// transferDir creates the directory name of dst, with its parents if root, the root of dst is left as is
+func transferDir(dst VFS, name string, root bool) error {
+	if name == "" || name == "." || name == "/" {
+		return nil
+	}
+
+	mkdir, ok := dst.(interface {
+		Mkdir(name string, perm fs.FileMode) error
+	})
+	if !ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errors.ErrUnsupported}
+	}
+
+	var err error
+	if all, ok := dst.(interface {
+		MkdirAll(name string, perm fs.FileMode) error
+	}); ok && root {
+		err = all.MkdirAll(name, fs.ModePerm)
+	} else {
+		err = mkdir.Mkdir(name, fs.ModePerm)
+	}
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// transferFile streams srcName of src into dstName of dst, sending the size as Content-Length if the file of dst can,
+// then verifies what dst has
+func transferFile(dst VFS, dstName string, src VFS, srcName string, progress func(name string, done, total int64)) error {
+	srcFile, err := src.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	stat, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstFile, err := openForWrite(dst, dstName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dstFile.Close()
+	}()
+
+	readerFrom, ok := dstFile.(io.ReaderFrom)
+	if !ok {
+		return &fs.PathError{Op: "transfer", Path: dstName, Err: errors.ErrUnsupported}
+	}
+
+	hasher := sha256.New()
+	reporter := &progressWriter{total: stat.Size(), step: DefaultProgressStep}
+	if progress != nil {
+		reporter.progress = func(done, total int64) {
+			progress(srcName, done, total)
+		}
+	}
+
+	err = pipe(srcFile, func(reader io.Reader) error {
+		if sized, ok := dstFile.(interface {
+			ReadFromSized(reader io.Reader, size int64) (int64, error)
+		}); ok && stat.Size() >= 0 {
+			_, err := sized.ReadFromSized(reader, stat.Size())
+			return err
+		}
+		_, err := readerFrom.ReadFrom(reader)
+		return err
+	}, reporter, hasher)
+	if err != nil {
+		return err
+	}
+
+	reporter.flush()
+
+	return verifyTransfer(dst, dstName, reporter.done, hasher.Sum(nil))
+}
+
+// verifyTransfer checks name of dst has size bytes hashing to sum, with the sha256 computed by dst if it can
+func verifyTransfer(dst VFS, name string, size int64, sum []byte) error {
+	stat, err := dst.Stat(name)
+	if err != nil {
+		return err
+	}
+	if stat.Size() != size {
+		return &fs.PathError{Op: "transfer", Path: name, Err: ErrChecksumMismatch}
+	}
+
+	var dstSum []byte
+	if hasher, ok := dst.(interface {
+		Hash(name string) (HashString, error)
+	}); ok {
+		hash, err := hasher.Hash(name)
+		if err != nil && !errors.Is(err, ErrHashUnsupported) {
+			return err
+		}
+		if err == nil {
+			dstSum, err = hex.DecodeString(string(hash))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if dstSum == nil {
+		file, err := dst.Open(name)
+		if err != nil {
+			return err
+		}
+		dstSum, err = sha256Sum(file)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !bytes.Equal(dstSum, sum) {
+		return &fs.PathError{Op: "transfer", Path: name, Err: ErrChecksumMismatch}
+	}
+	return nil
+}
+
+// openForWrite opens name of vfs, creating it with OpenFile if the VFS has it and Open fails for a missing file,
+// as with StrictFS
+func openForWrite(vfs VFS, name string) (fs.File, error) {
+	file, err := vfs.Open(name)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+
+	opener, ok := vfs.(interface {
+		OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	})
+	if !ok {
+		return nil, err
+	}
+	return opener.OpenFile(name, os.O_WRONLY|os.O_CREATE, fs.ModePerm)
+}
+
+// WriteToWithProgress
+// Same as WriteTo, calling progress every DefaultProgressStep bytes and once at the end,
+// with the size from CachedStat, less the offset if a stream is open, as total
+func (d *DufsFile) WriteToWithProgress(writer io.Writer, progress ProgressFunc) (int64, error) {
+	stat, err := d.CachedStat()
+	if err != nil {
+		return 0, err
+	}
+
+	total := int64(-1)
+	if !stat.IsDir() {
+		total = stat.Size()
+		if d.stream != nil {
+			total -= d.Tell()
+		}
+	}
+
+	reporter := &progressWriter{total: total, step: DefaultProgressStep, progress: progress}
+	n, err := d.WriteTo(io.MultiWriter(writer, reporter))
+	reporter.flush()
+
+	return n, err
+}
@@ -0,0 +1,218 @@
+package vfs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestDufsTransfer(t *testing.T) {
+	server := newFakeDufs(t)
+
+	data := make([]byte, 3<<20+17)
+	_, err := rand.Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.put("src/large.bin", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls, last, total int64
+	err = dufs.Transfer("dst/large.bin", "src/large.bin", func(done, size int64) {
+		if done < last {
+			t.Error("progress should not go backwards")
+		}
+		calls++
+		last, total = done, size
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls == 0 || last != int64(len(data)) || total != int64(len(data)) {
+		t.Fatal("progress should reach", len(data), "got", last, "of", total, "in", calls, "calls")
+	}
+
+	copied, ok := server.get("dst/large.bin")
+	if !ok || !bytes.Equal(copied, data) {
+		t.Fatal("dst/large.bin should have the same content as src/large.bin")
+	}
+}
+
+func TestDufsWriteToWithProgress(t *testing.T) {
+	server := newFakeDufs(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 256*1024)
+	server.put("large.bin", data)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		calls      int
+		done, last int64
+		total      int64
+	)
+	buf := bytes.NewBuffer(nil)
+	n, err := file.(*DufsFile).WriteToWithProgress(buf, func(d, t int64) {
+		calls++
+		last, done, total = done, d, t
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("WriteToWithProgress should copy the whole file, got", n, "bytes")
+	}
+	if done != total || total != int64(len(data)) {
+		t.Fatal("progress should end at", len(data), "got", done, "of", total)
+	}
+	if last >= done {
+		t.Fatal("progress should increase, got", done, "after", last)
+	}
+	if maxCalls := len(data)/DefaultProgressStep + 1; calls > maxCalls {
+		t.Fatal("progress should be reported at most", maxCalls, "times, got", calls)
+	}
+}
+
+func TestMoveAcrossServers(t *testing.T) {
+	srcServer := newFakeDufs(t)
+	dstServer := newFakeDufs(t)
+
+	large := make([]byte, 1<<20+3)
+	_, err := rand.Read(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := map[string][]byte{
+		"tree/a.txt":          []byte("a"),
+		"tree/sub/b.txt":      []byte("bb"),
+		"tree/sub/deep/c.bin": large,
+	}
+	for name, data := range tree {
+		srcServer.put(name, data)
+	}
+	srcServer.mkdir("tree/empty")
+
+	src, err := NewDufsVFS(srcServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewDufsVFS(dstServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var locker sync.Mutex
+	progress := map[string]int64{}
+	err = TransferWithOpts(dst, "moved/tree", src, "tree", TransferOpts{
+		RemoveSource: true,
+		Progress: func(name string, done, total int64) {
+			locker.Lock()
+			progress[name] = done
+			locker.Unlock()
+			if total != int64(len(tree[name])) {
+				t.Error("the total of", name, "should be its size, got", total)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range tree {
+		copied, ok := dstServer.get("moved/" + name)
+		if !ok || sha256.Sum256(copied) != sha256.Sum256(data) {
+			t.Fatal("moved/"+name, "should have the same hash as", name)
+		}
+		if progress[name] != int64(len(data)) {
+			t.Fatal("progress of", name, "should reach", len(data), "got", progress[name])
+		}
+	}
+	if !dstServer.dirs["moved/tree/empty"] {
+		t.Fatal("empty directories should be transferred too")
+	}
+	if _, ok := srcServer.get("tree/a.txt"); ok || srcServer.dirs["tree"] {
+		t.Fatal("the source should be removed after the move")
+	}
+
+	srcServer.put("single.txt", []byte("single"))
+	err = Transfer(dst, "copied.txt", src, "single.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied, _ := dstServer.get("copied.txt"); string(copied) != "single" {
+		t.Fatal("a single file should be transferred, got", string(copied))
+	}
+	if _, ok := srcServer.get("single.txt"); !ok {
+		t.Fatal("Transfer should keep the source")
+	}
+}
+
+func TestMoveAcrossServersCorrupted(t *testing.T) {
+	srcServer := newFakeDufs(t)
+	dstServer := newFakeDufs(t)
+
+	// proxy flips the first byte of every upload to dstServer
+	target, err := url.Parse(dstServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forward := httputil.NewSingleHostReverseProxy(target)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if len(data) > 0 {
+				data[0] ^= 0xff
+			}
+			r.Body = io.NopCloser(bytes.NewReader(data))
+		}
+		forward.ServeHTTP(w, r)
+	}))
+	t.Cleanup(proxy.Close)
+
+	src, err := NewDufsVFS(srcServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewDufsVFS(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, noHash := range []bool{false, true} {
+		dstServer.NoHash = noHash
+		srcServer.put("tree/a.txt", []byte("a"))
+
+		err = MoveAcrossServers(dst, "moved", src, "tree")
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatal("a corrupted upload should fail with ErrChecksumMismatch, NoHash", noHash, "got", err)
+		}
+		if data, ok := srcServer.get("tree/a.txt"); !ok || string(data) != "a" {
+			t.Fatal("the source should be kept when the transfer is not verified, NoHash", noHash)
+		}
+	}
+}
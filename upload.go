@@ -0,0 +1,202 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync/atomic"
+)
+
+const DefaultPartSize = 8 << 20
+
+func (d *DufsVFS) GetPartSize() int64 {
+	if d.PartSize <= 0 {
+		return DefaultPartSize
+	}
+	return d.PartSize
+}
+
+// putInParts uploads reader as an empty PUT followed by a PATCH per part, a negative size reads reader until EOF
+func (d *DufsFile) putInParts(ctx context.Context, reader io.Reader, size int64, contentType string) (int64, error) {
+	_, err := d.putOnce(ctx, bytes.NewReader(nil), 0, contentType)
+	if err != nil {
+		return 0, err
+	}
+
+	if size >= 0 {
+		reader = io.LimitReader(reader, size+1)
+	}
+
+	part := make([]byte, d.vfs.GetPartSize())
+	total := int64(0)
+
+	for {
+		n, err := io.ReadFull(reader, part)
+		if n > 0 {
+			if size >= 0 && total+int64(n) > size {
+				return total, fmt.Errorf("dufs: expected %d bytes, read more", size)
+			}
+			err := d.patch(ctx, part[:n], total)
+			if err != nil {
+				return total, err
+			}
+			total += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return total, err
+		}
+	}
+
+	if size >= 0 && total != size {
+		return total, fmt.Errorf("dufs: expected %d bytes, read %d", size, total)
+	}
+
+	return total, nil
+}
+
+// progressReader reports the bytes read from reader to progress, following reader when it is seeked
+type progressReader struct {
+	reader   io.Reader
+	origin   int64
+	done     int64
+	total    int64
+	progress ProgressFunc
+}
+
+// newProgressReader reports the reading of reader, whose total is size, or its remaining length if size is negative
+func newProgressReader(reader io.Reader, size int64, progress ProgressFunc) *progressReader {
+	d := &progressReader{reader: reader, total: size, progress: progress}
+
+	if seeker, ok := reader.(io.Seeker); ok {
+		d.origin, _ = seeker.Seek(0, io.SeekCurrent)
+	}
+
+	if d.total < 0 {
+		d.total = remainingLength(reader, d.origin)
+	}
+
+	return d
+}
+
+// remainingLength is the bytes left in a bytes.Reader, strings.Reader or os.File from its offset, -1 for other readers
+func remainingLength(reader io.Reader, offset int64) int64 {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Stat() (fs.FileInfo, error) }:
+		stat, err := r.Stat()
+		if err != nil || !stat.Mode().IsRegular() {
+			return -1
+		}
+		return stat.Size() - offset
+	}
+	return -1
+}
+
+func (d *progressReader) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	if n > 0 {
+		d.done += int64(n)
+		d.progress(d.done, d.total)
+	}
+	return n, err
+}
+
+func (d *progressReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := d.reader.(io.Seeker)
+	if !ok {
+		return 0, errors.New("dufs: body is not seekable")
+	}
+
+	position, err := seeker.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	d.done = position - d.origin
+
+	return position, nil
+}
+
+// ResumeOffset
+// Returns the size of the file on the server, 0 if it does not exist, which is where UploadResumable continues from
+func (d *DufsFile) ResumeOffset() (int64, error) {
+	stat, err := d.Stat()
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if stat.IsDir() {
+		return 0, &fs.PathError{Op: "upload", Path: d.Name, Err: fs.ErrInvalid}
+	}
+	return stat.Size(), nil
+}
+
+// UploadResumable
+// Uploads reader, from its beginning, with a PATCH per chunkSize, PartSize if 0.
+// The bytes already on the server are skipped, seeking reader if possible, so an interrupted upload is resumed
+// by calling it again with the same content. Returns the offset committed on the server, even on failure
+func (d *DufsFile) UploadResumable(reader io.Reader, chunkSize int64) (int64, error) {
+	err := d.checkAccess("write", true)
+	if err != nil {
+		return 0, err
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = d.vfs.GetPartSize()
+	}
+
+	d.indexLocker.Lock()
+	defer d.indexLocker.Unlock()
+
+	committed, err := d.ResumeOffset()
+	if err != nil {
+		return 0, err
+	}
+
+	if committed == 0 {
+		_, err = d.upload(d.getContext(), bytes.NewReader(nil), 0)
+		if err != nil {
+			return 0, err
+		}
+	} else if seeker, ok := reader.(io.Seeker); ok {
+		_, err = seeker.Seek(committed, io.SeekStart)
+		if err != nil {
+			return committed, err
+		}
+	} else {
+		_, err = io.CopyN(io.Discard, reader, committed)
+		if err == io.EOF {
+			return committed, errors.New("dufs: file on the server is larger than reader")
+		} else if err != nil {
+			return committed, err
+		}
+	}
+
+	chunk := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		if n > 0 {
+			perr := d.patch(d.getContext(), chunk[:n], committed)
+			if perr != nil {
+				return committed, perr
+			}
+			committed += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return committed, err
+		}
+	}
+
+	_ = d.closeStream()
+	atomic.StoreInt64(&d.index, 0)
+
+	return committed, nil
+}
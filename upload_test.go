@@ -0,0 +1,199 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestDufsSmallFileThreshold(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufs.SmallFileThreshold = 1024
+	dufs.PartSize = 1000
+
+	small := bytes.Repeat([]byte("s"), 1000)
+	large := bytes.Repeat([]byte("0123456789"), 250)
+
+	for _, c := range []struct {
+		name     string
+		data     []byte
+		sized    bool
+		requests int64
+	}{
+		{"small-sized.txt", small, true, 1},
+		{"small.txt", small, false, 1},
+		// an empty PUT, then 3 PATCHes of 1000, 1000 and 500 bytes
+		{"large-sized.txt", large, true, 4},
+		{"large.txt", large, false, 4},
+	} {
+		file, err := dufs.Open(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before := server.Requests.Load()
+
+		var n int64
+		if c.sized {
+			n, err = file.(*DufsFile).ReadFromSized(bytes.NewReader(c.data), int64(len(c.data)))
+		} else {
+			n, err = file.(io.ReaderFrom).ReadFrom(io.MultiReader(bytes.NewReader(c.data)))
+		}
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if n != int64(len(c.data)) {
+			t.Fatal(c.name, "should upload", len(c.data), "bytes, got", n)
+		}
+
+		if requests := server.Requests.Load() - before; requests != c.requests {
+			t.Fatal(c.name, "should take", c.requests, "requests, got", requests)
+		}
+
+		data, _ := server.get(c.name)
+		if !bytes.Equal(data, c.data) {
+			t.Fatal(c.name, "content mismatch, got", len(data), "bytes")
+		}
+	}
+
+	file, err := dufs.Open("short.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.(*DufsFile).ReadFromSized(bytes.NewReader(large), int64(len(large))+1)
+	if err == nil {
+		t.Fatal("uploading fewer bytes than the size should fail")
+	}
+}
+
+func TestDufsUploadProgress(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := bytes.Repeat([]byte("progress"), 100000)
+
+	var (
+		last      int64
+		totals    []int64
+		decreased error
+	)
+	dufs.UploadProgress = func(done, total int64) {
+		if done < last && decreased == nil {
+			decreased = fmt.Errorf("progress should increase, got %d after %d", done, last)
+		}
+		last = done
+		totals = append(totals, total)
+	}
+
+	file, err := dufs.Open("progress.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = file.(*DufsFile).ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decreased != nil {
+		t.Fatal(decreased)
+	}
+	if last != int64(len(data)) {
+		t.Fatal("progress should end at", len(data), "got", last)
+	}
+	for _, total := range totals {
+		if total != int64(len(data)) {
+			t.Fatal("total of a bytes.Reader should be", len(data), "got", total)
+		}
+	}
+
+	last, totals = 0, nil
+	_, err = file.(*DufsFile).ReadFrom(io.MultiReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != int64(len(data)) || totals[len(totals)-1] != -1 {
+		t.Fatal("progress of an unknown length should end at", len(data), "of -1, got", last, "of", totals[len(totals)-1])
+	}
+}
+
+// failingReader fails with errInjectedFault once Limit bytes were read
+type failingReader struct {
+	Reader io.Reader
+	Limit  int64
+	read   int64
+}
+
+func (d *failingReader) Read(p []byte) (int, error) {
+	if d.read >= d.Limit {
+		return 0, errInjectedFault
+	}
+	p = p[:min(int64(len(p)), d.Limit-d.read)]
+	n, err := d.Reader.Read(p)
+	d.read += int64(n)
+	return n, err
+}
+
+func TestDufsUploadResumable(t *testing.T) {
+	server := newFakeDufs(t)
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	file, err := dufs.Open("resumable.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dufsFile := file.(*DufsFile)
+
+	committed, err := dufsFile.UploadResumable(&failingReader{Reader: bytes.NewReader(data), Limit: 4500}, 1000)
+	if !errors.Is(err, errInjectedFault) {
+		t.Fatal("interrupted upload should fail with the reader error, got", err)
+	}
+	if committed != 4500 {
+		t.Fatal("interrupted upload should commit the 4500 bytes read, got", committed)
+	}
+
+	offset, err := dufsFile.ResumeOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != committed {
+		t.Fatal("ResumeOffset should be", committed, "got", offset)
+	}
+
+	before := server.Requests.Load()
+
+	// not seekable, so the committed bytes are skipped by reading them
+	committed, err = dufsFile.UploadResumable(io.MultiReader(bytes.NewReader(data)), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if committed != int64(len(data)) {
+		t.Fatal("resumed upload should commit", len(data), "bytes, got", committed)
+	}
+
+	// a HEAD, then a PATCH per remaining chunk
+	if requests := server.Requests.Load() - before; requests != 7 {
+		t.Fatal("resumed upload should take 7 requests, got", requests)
+	}
+
+	uploaded, _ := server.get("resumable.bin")
+	if !bytes.Equal(uploaded, data) {
+		t.Fatal("resumed upload content mismatch, got", len(uploaded), "bytes")
+	}
+}
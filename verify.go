@@ -0,0 +1,49 @@
+package vfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// HashString is a hex encoded sha256
+type HashString string
+
+// DownloadAndVerify
+// Downloads the file into w while hashing it, and fails with ErrChecksumMismatch after the copy if it does not match expected.
+// Nothing is buffered, so w has received the whole content even if it fails
+func (d *DufsFile) DownloadAndVerify(w io.Writer, expected HashString) error {
+	hasher := sha256.New()
+
+	_, err := d.WriteTo(io.MultiWriter(w, hasher))
+	if err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(actual, string(expected)) {
+		logFields(d.FS.GetLogger(), "Verify download", "url", d.Href.String(), "sha256", actual, "expected", expected)
+		return &fs.PathError{Op: "verify", Path: d.Name, Err: ErrChecksumMismatch}
+	}
+
+	return nil
+}
+
+// ReadFromWithHash
+// Same as ReadFrom, while hashing the uploaded bytes with h, sha256 if nil.
+// The body can not be rewound, so a single PUT is not retried
+func (d *DufsFile) ReadFromWithHash(reader io.Reader, h hash.Hash) (int64, []byte, error) {
+	if h == nil {
+		h = sha256.New()
+	}
+
+	n, err := d.put(d.getContext(), NewHashReader(reader, h), -1)
+	if err != nil {
+		return n, nil, err
+	}
+
+	return n, h.Sum(nil), nil
+}
@@ -0,0 +1,94 @@
+package vfs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestDufsDownloadAndVerify(t *testing.T) {
+	server := newFakeDufs(t)
+	data := bytes.Repeat([]byte("verify"), 100000)
+	server.put("verify.bin", data)
+
+	hash, err := Sha256(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("verify.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = file.(*DufsFile).DownloadAndVerify(io.Discard, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong, err := Sha256([]byte("something else"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = file.(*DufsFile).DownloadAndVerify(io.Discard, wrong)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatal("a wrong hash should fail with ErrChecksumMismatch, got", err)
+	}
+}
+
+func TestDufsReadFromWithHash(t *testing.T) {
+	server := newFakeDufs(t)
+
+	hash, filename, data, err := CreateTestData()
+	t.Cleanup(func() {
+		_ = os.Remove(path.Join(TestDataFolder, string(filename)))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dufs.Open("hashed.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, sum, err := file.(*DufsFile).ReadFromWithHash(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Fatal("should upload", len(data), "bytes, got", n)
+	}
+	if HashString(hex.EncodeToString(sum)) != hash {
+		t.Fatal("sha256 should be", hash, "got", hex.EncodeToString(sum))
+	}
+
+	uploaded, _ := server.get("hashed.bin")
+	if !bytes.Equal(uploaded, data) {
+		t.Fatal("uploaded content mismatch")
+	}
+
+	_, sum, err = file.(*DufsFile).ReadFromWithHash(bytes.NewReader(data), md5.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := md5.Sum(data); !bytes.Equal(sum, expected[:]) {
+		t.Fatal("md5 should be", hex.EncodeToString(expected[:]), "got", hex.EncodeToString(sum))
+	}
+}
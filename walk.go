@@ -0,0 +1,159 @@
+package vfs
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// WalkDir
+// Same as fs.WalkDir, but fetches directory listings ahead of the traversal when PrefetchWidth and PrefetchDepth are set
+func (d *HttpVFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	var p *prefetcher
+	if d.PrefetchWidth > 0 && d.PrefetchDepth > 0 {
+		p = &prefetcher{
+			fsys:    d,
+			depth:   d.PrefetchDepth,
+			sem:     make(chan struct{}, d.PrefetchWidth),
+			pending: make(map[string]*prefetch),
+		}
+		defer p.stopped.Store(true)
+	}
+
+	info, err := d.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = d.walkDir(root, fs.FileInfoToDirEntry(info), fn, p)
+	}
+
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+func (d *HttpVFS) walkDir(name string, entry fs.DirEntry, fn fs.WalkDirFunc, p *prefetcher) error {
+	if err := fn(name, entry, nil); err != nil || !entry.IsDir() {
+		if err == fs.SkipDir && entry.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := p.readDir(d, name)
+	if err != nil {
+		err = fn(name, entry, err)
+		if err != nil {
+			if err == fs.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, child := range entries {
+		err = d.walkDir(path.Join(name, child.Name()), child, fn, p)
+		if err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *HttpVFS) readDirSorted(name string) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDir(name)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, err
+}
+
+type prefetch struct {
+	done    chan struct{}
+	entries []fs.DirEntry
+	err     error
+}
+
+type prefetcher struct {
+	fsys    *HttpVFS
+	depth   int
+	sem     chan struct{}
+	stopped atomic.Bool
+
+	locker  sync.Mutex
+	pending map[string]*prefetch
+}
+
+// readDir returns the prefetched listing of name if there is one, and schedules its subdirectories
+func (p *prefetcher) readDir(fsys *HttpVFS, name string) ([]fs.DirEntry, error) {
+	if p == nil {
+		return fsys.readDirSorted(name)
+	}
+
+	p.locker.Lock()
+	pf, ok := p.pending[name]
+	delete(p.pending, name)
+	p.locker.Unlock()
+
+	if !ok {
+		entries, err := fsys.readDirSorted(name)
+		if err == nil {
+			p.schedule(name, entries, p.depth)
+		}
+		return entries, err
+	}
+
+	<-pf.done
+	if pf.err == nil {
+		p.schedule(name, pf.entries, p.depth)
+	}
+
+	return pf.entries, pf.err
+}
+
+func (p *prefetcher) schedule(dir string, entries []fs.DirEntry, depth int) {
+	if depth <= 0 || p.stopped.Load() {
+		return
+	}
+
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		if _, ok := p.pending[name]; ok {
+			continue
+		}
+		pf := &prefetch{done: make(chan struct{})}
+		p.pending[name] = pf
+		go p.fetch(name, pf, depth)
+	}
+}
+
+func (p *prefetcher) fetch(name string, pf *prefetch, depth int) {
+	defer close(pf.done)
+
+	p.sem <- struct{}{}
+	if p.stopped.Load() {
+		<-p.sem
+		pf.err = fs.SkipAll
+		return
+	}
+	pf.entries, pf.err = p.fsys.readDirSorted(name)
+	<-p.sem
+
+	if pf.err == nil {
+		p.schedule(name, pf.entries, depth-1)
+	}
+}
@@ -0,0 +1,127 @@
+package vfs
+
+import (
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newWideTree(t *testing.T) *fakeDufs {
+	server := newFakeDufs(t)
+	for i := 0; i < 6; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 2; k++ {
+				server.put(fmt.Sprintf("d%d/s%d/f%d.txt", i, j, k), []byte("hello"))
+			}
+		}
+	}
+	return server
+}
+
+func walkPaths(t *testing.T, dufs *DufsVFS, skip string) []string {
+	var visited []string
+	err := dufs.WalkDir("", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, name)
+		if name == skip {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return visited
+}
+
+func TestDufsWalkDirPrefetch(t *testing.T) {
+	server := newWideTree(t)
+	server.Delay = 20 * time.Millisecond
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	serial := walkPaths(t, dufs, "d1")
+	serialDuration := time.Since(start)
+
+	dufs.PrefetchWidth = 8
+	dufs.PrefetchDepth = 2
+
+	start = time.Now()
+	prefetched := walkPaths(t, dufs, "d1")
+	prefetchedDuration := time.Since(start)
+
+	t.Log("serial:", serialDuration, "prefetched:", prefetchedDuration)
+
+	if !slices.Equal(serial, prefetched) {
+		t.Fatal("traversal order mismatch:", serial, prefetched)
+	}
+
+	if slices.Contains(serial, "d1/s0") {
+		t.Fatal("d1 should be skipped")
+	}
+
+	if !slices.Contains(serial, "d5/s3/f1.txt") {
+		t.Fatal("d5/s3/f1.txt should be visited")
+	}
+
+	if prefetchedDuration > serialDuration*2/3 {
+		t.Fatal("prefetching should be noticeably faster than serial walk")
+	}
+}
+
+func TestDufsWalkDirOrder(t *testing.T) {
+	server := newFakeDufs(t)
+	for _, name := range []string{"b/2.txt", "b/1.txt", "a/z/deep.txt", "a/y.txt", "c.txt", "d/skipped.txt"} {
+		server.put(name, []byte(name))
+	}
+
+	dufs, err := NewDufsVFS(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(walkDir func(root string, fn fs.WalkDirFunc) error, skip map[string]error) []string {
+		var visited []string
+		err := walkDir(".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			visited = append(visited, name)
+			return skip[name]
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return visited
+	}
+	fsWalkDir := func(root string, fn fs.WalkDirFunc) error {
+		return fs.WalkDir(dufs, root, fn)
+	}
+
+	for _, c := range []struct {
+		skip     map[string]error
+		expected []string
+	}{
+		{nil, []string{".", "a", "a/y.txt", "a/z", "a/z/deep.txt", "b", "b/1.txt", "b/2.txt", "c.txt", "d", "d/skipped.txt"}},
+		{map[string]error{"a/z": fs.SkipDir, "b/1.txt": fs.SkipDir}, []string{".", "a", "a/y.txt", "a/z", "b", "b/1.txt", "c.txt", "d", "d/skipped.txt"}},
+		{map[string]error{"c.txt": fs.SkipAll}, []string{".", "a", "a/y.txt", "a/z", "a/z/deep.txt", "b", "b/1.txt", "b/2.txt", "c.txt"}},
+	} {
+		visited := walk(dufs.WalkDir, c.skip)
+		if !slices.Equal(visited, c.expected) {
+			t.Fatal("WalkDir with", c.skip, "should visit", c.expected, "got", visited)
+		}
+
+		visited = walk(fsWalkDir, c.skip)
+		if !slices.Equal(visited, c.expected) {
+			t.Fatal("fs.WalkDir with", c.skip, "should visit", c.expected, "got", visited)
+		}
+	}
+}